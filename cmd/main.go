@@ -1,145 +1,20 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
+	"flag"
 	"fmt"
-	"net"
 	"os"
 	"time"
 
 	dns "github.com/sadityakumar9211/go-res/internal/dns"
-	buf "github.com/sadityakumar9211/go-res/pkg/bytepacketbuffer"
+	"github.com/sadityakumar9211/go-res/internal/resolver"
+	"github.com/sadityakumar9211/go-res/internal/server"
 )
 
-// For now we're always starting with *a.root-servers.net*.
-const rootNameServer = "198.41.0.4"
-
-func lookup(qname string, qtype dns.QueryType, server string) (*dns.DnsPacket, error) {
-	// Forward queries to the specified DNS server
-
-	socket, err := net.Dial("udp", server)
-	if err != nil {
-		return nil, err
-	}
-	defer socket.Close()
-
-	// 5 second deadline for read and write operation to this socket.
-	socket.SetDeadline(time.Now().Add(5 * time.Second))
-
-	packet := dns.NewDnsPacket()
-	packet.Header.ID = 6666
-	packet.Header.Questions = 1
-	packet.Header.RecursionDesired = true
-	packet.Questions = append(packet.Questions, &dns.DnsQuestion{Name: qname, QType: qtype})
-
-	reqBuffer := buf.BytePacketBuffer{}
-	if err := packet.Write(&reqBuffer); err != nil {
-		return nil, err
-	}
-
-	_, err = socket.Write(reqBuffer.Buf[:reqBuffer.GetPos()])
-	if err != nil {
-		return nil, err
-	}
-
-	resBuffer := buf.NewBytePacketBuffer()
-	buffer := make([]byte, 512)
-	_, err = socket.Read(buffer)
-	if err != nil {
-		return nil, err
-	}
-	copy(resBuffer.Buf[:], buffer)
-
-	resPacket, err := dns.FromBuffer(&resBuffer)
-	if err != nil {
-		return nil, err
-	}
-	jsonData, err := json.MarshalIndent(resPacket, "  ", "   ")
-	if err != nil {
-		fmt.Println("Error marshling to JSON: ", err)
-		return resPacket, nil
-	}
-	fmt.Println(string(jsonData))
-	return resPacket, nil
-}
-
-func recursiveLookup(qname string, qtype dns.QueryType) (*dns.DnsPacket, error) {
-	ns := net.ParseIP(rootNameServer)
-
-	// Since it might take an arbitrary number of steps, we enter an unbounded loop.
-	for {
-		fmt.Printf("\nAttempting lookup of %v %v with NS %v\n", qtype, qname, ns)
-
-		addr := fmt.Sprintf("%s:53", ns.String())
-		response, err := lookup(qname, qtype, addr)
-		if err != nil {
-			return nil, err
-		}
-
-		if len(response.Answers) > 0 && response.Header.ResultCode == dns.NOERROR {
-			return response, nil
-		} else if response.Header.ResultCode == dns.NXDOMAIN {
-			return response, nil
-		}
-
-		// Otherwise, we'll try to find a new nameserver based on NS and a corresponding A
-		// record in the additional section. If this succeeds, we can switch name server
-		// and retry the loop.
-		newNS := response.GetResolvedNS(qname)
-		if newNS != nil {
-			ns = newNS
-			continue
-		}
-
-		// If not, we'll have to resolve the ip of a NS record. If no NS records exist,
-		// we'll go with what the last server told us.
-		for candidateNS := range response.GetNS(qname) {
-			if candidateNS.Host != "" {
-				recursiveResponse, err := recursiveLookup(candidateNS.Host, dns.A)
-				if err != nil {
-					continue
-				}
-				newNS := recursiveResponse.GetRandomA()
-				if newNS != nil {
-					ns = newNS
-					break
-				} else {
-					return response, nil
-				}
-			} else {
-				return response, nil
-			}
-		}
-	}
-}
-
-func handleQuery(socket *net.UDPConn) error {
-	// With a socket ready, we can go ahead and read a packet. This will
-	// block until one is received.
-	reqBuffer := buf.NewBytePacketBuffer()
-
-	// The `READFromUDP` function will write the data into the provided buffer,
-	// and return the length of the data read as well as the source address.
-	// We're not interested in the length, but we need to keep track of the
-	// source in order to send our reply later on.
-
-	// Taking input from `dig`.
-	_, src, err := socket.ReadFromUDP(reqBuffer.Buf[:])
-	if err != nil {
-		return err
-	}
-
-	request, err := dns.FromBuffer(&reqBuffer)
-	if err != nil {
-		return err
-	}
-
+func handleQuery(ctx context.Context, res *resolver.Resolver, request *dns.DnsPacket) *dns.DnsPacket {
 	// Create and initialize the response packet
-	response := dns.NewDnsPacket()
-	response.Header.ID = request.Header.ID
-	response.Header.RecursionDesired = true
-	response.Header.RecursionAvailable = true
-	response.Header.Response = true
+	response := server.NewResponse(request)
 
 	// In the normal case, exactly one question is present
 	if len(request.Questions) == 1 {
@@ -147,12 +22,19 @@ func handleQuery(socket *net.UDPConn) error {
 
 		fmt.Printf("Received query: %#v\n", question)
 
-		result, err := recursiveLookup(question.Name, question.QType)
+		// The tree we walk from the root servers is that of the Internet
+		// class, so questions for any other class are not implemented.
+		if question.Class() != dns.ClassIN {
+			fmt.Printf("Not resolving query of class %d\n", question.Class())
+			response.Header.ResultCode = dns.NOTIMP
+			return response
+		}
+
+		result, err := res.Resolve(ctx, question.Name, question.QType)
 		if err != nil {
+			fmt.Printf("Lookup of %v %v failed: %v\n", question.QType, question.Name, err)
 			response.Header.ResultCode = dns.SERVFAIL
 		} else {
-
-			response.Questions = append(response.Questions, question)
 			response.Header.ResultCode = result.Header.ResultCode
 
 			for _, rec := range result.Answers {
@@ -177,42 +59,87 @@ func handleQuery(socket *net.UDPConn) error {
 		fmt.Println("More than one question present...")
 	}
 
-	// The only thing remaining is to encode our response and send it off!
-	resBuffer := buf.NewBytePacketBuffer()
-	if err := response.Write(&resBuffer); err != nil {
-		return err
-	}
-
-	_, err = socket.WriteToUDP(resBuffer.Buf[:resBuffer.GetPos()], src)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return response
 }
 
 func main() { // endpoint for sending and receiving packets
-	// Bind a UDP socket on port 2053 to listen for DNS queries
-	// Listening to all available network interfaces at port 2053.
-	addr, err := net.ResolveUDPAddr("udp", "0.0.0.0:2053")
-	if err != nil {
-		fmt.Println("Error resolving UDP address:", err)
+	config := server.DefaultConfig()
+	resolverConfig := resolver.DefaultConfig()
+
+	// Listening to all available network interfaces at port 2053 by default.
+	flag.StringVar(&config.Addr, "addr", config.Addr, "address to listen on for DNS queries")
+	flag.IntVar(&config.Workers, "workers", config.Workers, "maximum number of queries resolved concurrently")
+	flag.IntVar(&config.QueueSize, "queue", config.QueueSize, "maximum number of queries waiting for a free worker")
+	flag.DurationVar(&config.QueryTimeout, "timeout", config.QueryTimeout, "maximum time spent resolving a single query")
+	flag.BoolVar(&config.DropWhenBusy, "drop-when-busy", config.DropWhenBusy, "drop queries instead of answering REFUSED when saturated")
+	flag.DurationVar(&config.IdleTimeout, "tcp-idle-timeout", config.IdleTimeout, "how long an idle TCP connection is kept open")
+	flag.IntVar(&config.MaxUDPSize, "max-udp-size", config.MaxUDPSize, "largest UDP response sent to clients using EDNS")
+	flag.BoolVar(&config.DisableCompression, "no-compression", config.DisableCompression, "write names in responses without compression, for debugging")
+	upstreamTCP := flag.Bool("upstream-tcp", false, "query other name servers over TCP instead of UDP")
+	flag.StringVar(&resolverConfig.IPMode, "ip-mode", resolverConfig.IPMode, "address families used to reach other name servers: dual, ipv4 or ipv6")
+	flag.IntVar(&resolverConfig.CacheSize, "cache-size", resolverConfig.CacheSize, "memory used by the answer cache, in bytes")
+	flag.DurationVar(&resolverConfig.MaxNegativeTTL, "max-negative-ttl", resolverConfig.MaxNegativeTTL, "upper bound on caching NXDOMAIN and NODATA answers")
+	rootHintsPath := flag.String("root-hints", "", "root hints file to use instead of the built-in root servers")
+	flag.BoolVar(&resolverConfig.CaseRandomisation, "case-randomisation", resolverConfig.CaseRandomisation, "randomise the case of query names sent to other name servers (0x20 encoding)")
+	flag.BoolVar(&resolverConfig.DisableQNameMinimisation, "no-qname-minimisation", resolverConfig.DisableQNameMinimisation, "send the full query name to every name server instead of only the labels it needs")
+	flag.IntVar(&resolverConfig.MaxReferrals, "max-referrals", resolverConfig.MaxReferrals, "maximum number of referrals followed for a single query")
+	flag.IntVar(&resolverConfig.MaxDepth, "max-depth", resolverConfig.MaxDepth, "maximum nesting of name server name lookups")
+	flag.IntVar(&resolverConfig.MaxQueries, "max-queries", resolverConfig.MaxQueries, "maximum number of queries sent to other name servers for a single query")
+	statsInterval := flag.Duration("stats-interval", time.Minute, "how often to log resolver statistics, 0 to disable")
+	flag.Parse()
+
+	// The resolver gives up on a query when the server does.
+	resolverConfig.MaxTime = config.QueryTimeout
+
+	if *upstreamTCP {
+		resolverConfig.Network = "tcp"
+	}
+	switch resolverConfig.IPMode {
+	case resolver.DualStack, resolver.IPv4Only, resolver.IPv6Only:
+	default:
+		fmt.Printf("Invalid IP mode %q, expected dual, ipv4 or ipv6\n", resolverConfig.IPMode)
 		os.Exit(1)
 	}
+	if *rootHintsPath != "" {
+		hints, err := resolver.LoadRootHintsFile(*rootHintsPath)
+		if err != nil {
+			fmt.Println("Error loading root hints:", err)
+			os.Exit(1)
+		}
+		resolverConfig.RootHints = hints
+	}
 
-	socket, err := net.ListenUDP("udp", addr)
-	if err != nil {
-		fmt.Println("Error binding UDP socket:", err)
-		os.Exit(1)
+	res := resolver.New(resolverConfig)
+
+	// Ask the root servers for the current list of root servers before serving
+	// any queries. If that fails, the root hints will do.
+	ctx, cancel := context.WithTimeout(context.Background(), config.QueryTimeout)
+	if err := res.Prime(ctx); err != nil {
+		fmt.Println("Error priming root servers, using root hints:", err)
+	}
+	cancel()
+
+	if *statsInterval > 0 {
+		go logStats(res, *statsInterval)
 	}
-	defer socket.Close()
 
-	fmt.Println("DNS server is listening on port 2053...")
+	srv := server.New(config, func(ctx context.Context, request *dns.DnsPacket) *dns.DnsPacket {
+		return handleQuery(ctx, res, request)
+	})
 
-	// Loop to handle incoming DNS queries
-	for {
-		if err := handleQuery(socket); err != nil {
-			fmt.Println("Error handling DNS query:", err)
-		}
+	fmt.Printf("DNS server is listening on %v...\n", config.Addr)
+
+	if err := srv.ListenAndServe(); err != nil {
+		fmt.Println("Error serving DNS queries:", err)
+		os.Exit(1)
+	}
+}
+
+// logStats logs the resolver statistics every interval.
+func logStats(res *resolver.Resolver, interval time.Duration) {
+	for range time.Tick(interval) {
+		stats := res.Stats()
+		fmt.Printf("Resolved %d queries (%d coalesced), sent %d lookups (%d coalesced)\n",
+			stats.Resolutions, stats.CoalescedResolutions, stats.Lookups, stats.CoalescedLookups)
 	}
 }
@@ -0,0 +1,244 @@
+package cache
+
+import (
+	"container/heap"
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/sadityakumar9211/go-res/internal/dns"
+	"github.com/sadityakumar9211/go-res/pkg/bytepacketbuffer"
+)
+
+// entryOverhead is a rough estimate of the memory used by an entry on top of
+// the wire size of its records.
+const entryOverhead = 128
+
+// Key identifies a cached answer.
+type Key struct {
+	Name  string
+	QType dns.QueryType
+	Class uint16
+}
+
+// NewKey creates the key for the answer to a question for name. Names are
+// compared case-insensitively.
+func NewKey(name string, qtype dns.QueryType, class uint16) Key {
+	return Key{Name: dns.CanonicalName(name), QType: qtype, Class: class}
+}
+
+// entry is a cached record set. Negative entries remember that a name or
+// record type doesn't exist, and hold the SOA record proving it.
+type entry struct {
+	key      Key
+	records  []dns.DnsRecord
+	negative bool
+	rcode    dns.ResultCode
+	stored   time.Time
+	expires  time.Time
+	size     int
+
+	element *list.Element // position in the LRU list
+	index   int           // position in the expiry heap
+}
+
+// Cache is an in-memory, TTL aware cache of record sets. Entries are evicted
+// once they expire, or when the cache grows beyond its memory limit, in least
+// recently used order. It is safe for concurrent use.
+type Cache struct {
+	mu      sync.Mutex
+	maxSize int
+	size    int
+	entries map[Key]*entry
+	lru     *list.List  // most recently used at the front
+	expiry  expiryQueue // soonest expiring at the top
+
+	now func() time.Time
+}
+
+// New creates a cache using at most maxSize bytes of memory, as estimated
+// from the size of the records it holds.
+func New(maxSize int) *Cache {
+	return &Cache{
+		maxSize: maxSize,
+		entries: make(map[Key]*entry),
+		lru:     list.New(),
+		now:     time.Now,
+	}
+}
+
+// Get returns the records cached for key. The TTLs of the returned records are
+// decremented by the time spent in the cache.
+func (c *Cache) Get(key Key) ([]dns.DnsRecord, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.lookup(key)
+	if !ok || e.negative {
+		return nil, false
+	}
+	return e.age(c.now()), true
+}
+
+// GetNegative returns the result code and SOA record of a negative answer
+// cached for key. The TTL of the SOA record is decremented by the time spent
+// in the cache.
+func (c *Cache) GetNegative(key Key) (dns.ResultCode, []dns.DnsRecord, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.lookup(key)
+	if !ok || !e.negative {
+		return 0, nil, false
+	}
+	return e.rcode, e.age(c.now()), true
+}
+
+// Set caches records under key until the smallest of their TTLs runs out.
+func (c *Cache) Set(key Key, records []dns.DnsRecord) {
+	if len(records) == 0 {
+		return
+	}
+
+	ttl := records[0].GetTTL()
+	for _, record := range records[1:] {
+		if record.GetTTL() < ttl {
+			ttl = record.GetTTL()
+		}
+	}
+
+	c.store(&entry{key: key, records: records}, ttl)
+}
+
+// SetNegative caches a negative answer for key, NXDOMAIN for a name which
+// doesn't exist or NOERROR for a name without records of the requested type
+// (NODATA), for ttl seconds. The SOA record is served along with the answer.
+func (c *Cache) SetNegative(key Key, rcode dns.ResultCode, soa dns.DnsRecord, ttl uint32) {
+	// The SOA record is served with the negative TTL, as per RFC 2308 section 3.
+	records := []dns.DnsRecord{soa.WithTTL(ttl)}
+	c.store(&entry{key: key, records: records, negative: true, rcode: rcode}, ttl)
+}
+
+// store adds e to the cache for ttl seconds.
+func (c *Cache) store(e *entry, ttl uint32) {
+	if ttl == 0 {
+		return
+	}
+
+	e.size = entryOverhead + recordsSize(e.records)
+	if e.size > c.maxSize {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if old, ok := c.entries[e.key]; ok {
+		c.remove(old)
+	}
+
+	now := c.now()
+	e.stored = now
+	e.expires = now.Add(time.Duration(ttl) * time.Second)
+	e.element = c.lru.PushFront(e)
+	heap.Push(&c.expiry, e)
+	c.entries[e.key] = e
+	c.size += e.size
+
+	c.evict(now)
+}
+
+// lookup returns the live entry for key and marks it as recently used.
+// Expired entries are dropped on the way.
+func (c *Cache) lookup(key Key) (*entry, bool) {
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	if !c.now().Before(e.expires) {
+		c.remove(e)
+		return nil, false
+	}
+	c.lru.MoveToFront(e.element)
+	return e, true
+}
+
+// age returns copies of the records of e with their TTLs decremented by the
+// time spent in the cache.
+func (e *entry) age(now time.Time) []dns.DnsRecord {
+	elapsed := uint32(now.Sub(e.stored) / time.Second)
+	records := make([]dns.DnsRecord, 0, len(e.records))
+	for _, record := range e.records {
+		ttl := uint32(0)
+		if record.GetTTL() > elapsed {
+			ttl = record.GetTTL() - elapsed
+		}
+		records = append(records, record.WithTTL(ttl))
+	}
+	return records
+}
+
+// Len returns the number of entries in the cache.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// evict drops expired entries, then the least recently used ones until the
+// cache fits into its memory limit again.
+func (c *Cache) evict(now time.Time) {
+	for len(c.expiry) > 0 && !now.Before(c.expiry[0].expires) {
+		c.remove(c.expiry[0])
+	}
+	for c.size > c.maxSize {
+		c.remove(c.lru.Back().Value.(*entry))
+	}
+}
+
+// remove drops e from the cache.
+func (c *Cache) remove(e *entry) {
+	c.lru.Remove(e.element)
+	heap.Remove(&c.expiry, e.index)
+	delete(c.entries, e.key)
+	c.size -= e.size
+}
+
+// recordsSize estimates the memory used by records from their wire size.
+func recordsSize(records []dns.DnsRecord) int {
+	size := 0
+	for _, record := range records {
+		buffer := bytepacketbuffer.NewBytePacketBufferSize(bytepacketbuffer.MaxSize)
+		buffer.DisableCompression = true
+		n, _ := record.Write(&buffer)
+		size += int(n) + entryOverhead
+	}
+	return size
+}
+
+// expiryQueue is a min-heap of entries ordered by expiry time.
+type expiryQueue []*entry
+
+func (q expiryQueue) Len() int           { return len(q) }
+func (q expiryQueue) Less(i, j int) bool { return q[i].expires.Before(q[j].expires) }
+
+func (q expiryQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *expiryQueue) Push(x any) {
+	e := x.(*entry)
+	e.index = len(*q)
+	*q = append(*q, e)
+}
+
+func (q *expiryQueue) Pop() any {
+	old := *q
+	e := old[len(old)-1]
+	old[len(old)-1] = nil
+	*q = old[:len(old)-1]
+	return e
+}
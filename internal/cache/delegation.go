@@ -0,0 +1,137 @@
+package cache
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sadityakumar9211/go-res/internal/dns"
+	"github.com/sadityakumar9211/go-res/pkg/bytepacketbuffer"
+)
+
+// NameServer is a name server a zone is delegated to, along with the
+// addresses we know for it.
+type NameServer struct {
+	Host  string
+	Addrs []net.IP
+}
+
+// Delegation is a zone cut learned from a referral: the zone and the name
+// servers it was delegated to.
+type Delegation struct {
+	Zone    string
+	Servers []NameServer
+
+	expires time.Time
+}
+
+// Addrs returns the addresses of all name servers of the delegation.
+func (d *Delegation) Addrs() []net.IP {
+	addrs := make([]net.IP, 0)
+	for _, server := range d.Servers {
+		addrs = append(addrs, server.Addrs...)
+	}
+	return addrs
+}
+
+// clone returns a copy of d which can be changed without affecting d.
+func (d *Delegation) clone() *Delegation {
+	clone := *d
+	clone.Servers = make([]NameServer, 0, len(d.Servers))
+	for _, server := range d.Servers {
+		server.Addrs = append([]net.IP(nil), server.Addrs...)
+		clone.Servers = append(clone.Servers, server)
+	}
+	return &clone
+}
+
+// AddAddr records addr as an address of the name server host.
+func (d *Delegation) AddAddr(host string, addr net.IP) {
+	for i := range d.Servers {
+		if dns.EqualNames(d.Servers[i].Host, host) {
+			d.Servers[i].Addrs = append(d.Servers[i].Addrs, addr)
+		}
+	}
+}
+
+// Delegations caches the zone cuts the resolver walked through, so that new
+// resolutions can start at the closest enclosing zone instead of the root.
+// It is safe for concurrent use.
+type Delegations struct {
+	mu         sync.Mutex
+	maxEntries int
+	entries    map[string]*Delegation
+
+	now func() time.Time
+}
+
+// NewDelegations creates a delegation cache holding at most maxEntries zones.
+func NewDelegations(maxEntries int) *Delegations {
+	return &Delegations{
+		maxEntries: maxEntries,
+		entries:    make(map[string]*Delegation),
+		now:        time.Now,
+	}
+}
+
+// Set caches delegation for ttl seconds, replacing what was known for its zone.
+func (d *Delegations) Set(delegation *Delegation, ttl uint32) {
+	if ttl == 0 || len(delegation.Servers) == 0 {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.now()
+	zone := dns.CanonicalName(delegation.Zone)
+	if _, ok := d.entries[zone]; !ok && len(d.entries) >= d.maxEntries {
+		d.evict(now)
+	}
+
+	stored := delegation.clone()
+	stored.expires = now.Add(time.Duration(ttl) * time.Second)
+	d.entries[zone] = stored
+}
+
+// Closest returns a copy of the cached delegation of the closest zone
+// enclosing name for which at least one name server address is known.
+func (d *Delegations) Closest(name string) (*Delegation, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.now()
+	labels := bytepacketbuffer.SplitDNSName(dns.CanonicalName(name))
+	for i := range labels {
+		zone := strings.Join(labels[i:], ".")
+		delegation, ok := d.entries[zone]
+		if !ok {
+			continue
+		}
+		if !now.Before(delegation.expires) {
+			delete(d.entries, zone)
+			continue
+		}
+		if len(delegation.Addrs()) > 0 {
+			return delegation.clone(), true
+		}
+	}
+	return nil, false
+}
+
+// evict makes room for a new entry by dropping expired entries, or an
+// arbitrary one if none has expired.
+func (d *Delegations) evict(now time.Time) {
+	for zone, delegation := range d.entries {
+		if !now.Before(delegation.expires) {
+			delete(d.entries, zone)
+		}
+	}
+	for zone := range d.entries {
+		if len(d.entries) < d.maxEntries {
+			return
+		}
+		delete(d.entries, zone)
+	}
+}
@@ -0,0 +1,195 @@
+package cache
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/sadityakumar9211/go-res/internal/dns"
+)
+
+// statsDuration is how long what we measured about a name server is kept
+// without being updated.
+const statsDuration = 15 * time.Minute
+
+// maxSRTT bounds the smoothed round trip time of a name server, so that one
+// which failed a lot can recover in reasonable time.
+const maxSRTT = 10 * time.Second
+
+// serverStats is what we measured about the name server at an address.
+type serverStats struct {
+	srtt     time.Duration // smoothed round trip time
+	failures int           // failures since the last response
+	updated  time.Time
+}
+
+// serverKey identifies a name server address serving a zone.
+type serverKey struct {
+	addr string
+	zone string
+}
+
+// Infra remembers what the resolver learned about the name servers it talked
+// to, so that later queries can go to the fastest ones and stay away from the
+// ones which misbehaved. It is safe for concurrent use.
+type Infra struct {
+	mu         sync.Mutex
+	maxEntries int
+	lame       map[serverKey]time.Time // when the lameness expires
+	stats      map[string]*serverStats // by address
+
+	now func() time.Time
+}
+
+// NewInfra creates an infrastructure cache holding at most maxEntries servers.
+func NewInfra(maxEntries int) *Infra {
+	return &Infra{
+		maxEntries: maxEntries,
+		lame:       make(map[serverKey]time.Time),
+		stats:      make(map[string]*serverStats),
+		now:        time.Now,
+	}
+}
+
+// MarkLame records that the name server at addr failed to answer for zone,
+// because it didn't respond, refused or failed to answer, or isn't
+// authoritative for the zone it was delegated. The server is considered lame
+// for duration.
+func (i *Infra) MarkLame(addr net.IP, zone string, duration time.Duration) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	now := i.now()
+	key := serverKey{addr: addr.String(), zone: dns.CanonicalName(zone)}
+	if _, ok := i.lame[key]; !ok && len(i.lame) >= i.maxEntries {
+		i.evict(now)
+	}
+	i.lame[key] = now.Add(duration)
+}
+
+// IsLame reports whether the name server at addr is considered lame for zone.
+func (i *Infra) IsLame(addr net.IP, zone string) bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	key := serverKey{addr: addr.String(), zone: dns.CanonicalName(zone)}
+	expires, ok := i.lame[key]
+	if !ok {
+		return false
+	}
+	if !i.now().Before(expires) {
+		delete(i.lame, key)
+		return false
+	}
+	return true
+}
+
+// RecordRTT records that the name server at addr responded after rtt. The
+// smoothed round trip time moves an eighth of the way towards rtt, like the
+// one TCP keeps (RFC 6298).
+func (i *Infra) RecordRTT(addr net.IP, rtt time.Duration) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	stats, ok := i.lookupStats(addr)
+	if !ok {
+		stats = i.addStats(addr, rtt)
+	}
+	stats.srtt += (rtt - stats.srtt) / 8
+	stats.failures = 0
+	stats.updated = i.now()
+}
+
+// RecordFailure records that the name server at addr didn't respond within
+// timeout. Its smoothed round trip time is doubled, and is at least timeout.
+func (i *Infra) RecordFailure(addr net.IP, timeout time.Duration) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	stats, ok := i.lookupStats(addr)
+	if !ok {
+		stats = i.addStats(addr, timeout)
+	} else {
+		stats.srtt *= 2
+	}
+	if stats.srtt < timeout {
+		stats.srtt = timeout
+	}
+	if stats.srtt > maxSRTT {
+		stats.srtt = maxSRTT
+	}
+	stats.failures++
+	stats.updated = i.now()
+}
+
+// SRTT returns the smoothed round trip time of the name server at addr, and
+// the number of times it failed to respond since it last did. It reports
+// false when nothing is known about the server.
+func (i *Infra) SRTT(addr net.IP) (time.Duration, int, bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	stats, ok := i.lookupStats(addr)
+	if !ok {
+		return 0, 0, false
+	}
+	return stats.srtt, stats.failures, true
+}
+
+// lookupStats returns the live stats of addr. Stale stats are dropped on the
+// way.
+func (i *Infra) lookupStats(addr net.IP) (*serverStats, bool) {
+	key := addr.String()
+	stats, ok := i.stats[key]
+	if !ok {
+		return nil, false
+	}
+	if !i.now().Before(stats.updated.Add(statsDuration)) {
+		delete(i.stats, key)
+		return nil, false
+	}
+	return stats, true
+}
+
+// addStats starts keeping stats for addr, with srtt as the first estimate.
+func (i *Infra) addStats(addr net.IP, srtt time.Duration) *serverStats {
+	now := i.now()
+	if len(i.stats) >= i.maxEntries {
+		i.evictStats(now)
+	}
+	stats := &serverStats{srtt: srtt, updated: now}
+	i.stats[addr.String()] = stats
+	return stats
+}
+
+// evictStats makes room for new stats by dropping stale ones, or arbitrary
+// ones if none is stale.
+func (i *Infra) evictStats(now time.Time) {
+	for key, stats := range i.stats {
+		if !now.Before(stats.updated.Add(statsDuration)) {
+			delete(i.stats, key)
+		}
+	}
+	for key := range i.stats {
+		if len(i.stats) < i.maxEntries {
+			return
+		}
+		delete(i.stats, key)
+	}
+}
+
+// evict makes room for a new entry by dropping expired entries, or an
+// arbitrary one if none has expired.
+func (i *Infra) evict(now time.Time) {
+	for key, expires := range i.lame {
+		if !now.Before(expires) {
+			delete(i.lame, key)
+		}
+	}
+	for key := range i.lame {
+		if len(i.lame) < i.maxEntries {
+			return
+		}
+		delete(i.lame, key)
+	}
+}
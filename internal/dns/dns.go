@@ -21,6 +21,9 @@ const (
 	REFUSED  ResultCode = 5
 )
 
+// ClassIN is the Internet class, the only one we resolve.
+const ClassIN uint16 = 1
+
 // QueryType represents DNS query types.
 const (
 	UNKNOWN QueryType = iota
@@ -29,6 +32,14 @@ const (
 	CNAME
 	MX
 	AAAA
+	OPT
+	SOA
+	DNAME
+	TXT
+	PTR
+	SRV
+	SVCB
+	HTTPS
 )
 
 // DnsHeader represents header of DNS packet.
@@ -162,10 +173,26 @@ func QueryTypeFromNum(num uint16) QueryType {
 		return NS
 	case 5:
 		return CNAME
+	case 6:
+		return SOA
+	case 12:
+		return PTR
+	case 39:
+		return DNAME
 	case 15:
 		return MX
+	case 16:
+		return TXT
 	case 28:
 		return AAAA
+	case 33:
+		return SRV
+	case 41:
+		return OPT
+	case 64:
+		return SVCB
+	case 65:
+		return HTTPS
 	default:
 		return UNKNOWN
 	}
@@ -180,10 +207,26 @@ func (q QueryType) QueryTypeToNum() uint16 {
 		return 2
 	case CNAME:
 		return 5
+	case SOA:
+		return 6
+	case PTR:
+		return 12
+	case DNAME:
+		return 39
 	case MX:
 		return 15
+	case TXT:
+		return 16
 	case AAAA:
 		return 28
+	case SRV:
+		return 33
+	case OPT:
+		return 41
+	case SVCB:
+		return 64
+	case HTTPS:
+		return 65
 	default:
 		return 0 // UNKNOWN
 	}
@@ -191,8 +234,9 @@ func (q QueryType) QueryTypeToNum() uint16 {
 
 // DnsQuestion represents a DNS question.
 type DnsQuestion struct {
-	Name  string
-	QType QueryType
+	Name   string
+	QType  QueryType
+	QClass uint16 // ClassIN when zero
 }
 
 // Read reads DNS question data from the buffer.
@@ -211,19 +255,32 @@ func (q *DnsQuestion) Read(buffer *bytepacketbuffer.BytePacketBuffer) error {
 
 	q.QType = QueryTypeFromNum(queryTypeFromNum)
 
-	if _, err = buffer.ReadU16(); err != nil {
+	class, err := buffer.ReadU16()
+	if err != nil {
 		return err
-	} // class
+	}
+	q.QClass = class
 
 	return nil
 }
 
 // Write writes DNS question data to the buffer.
 func (q *DnsQuestion) Write(buffer *bytepacketbuffer.BytePacketBuffer) error {
-	buffer.WriteQName(q.Name)
-	buffer.WriteU16(q.QType.QueryTypeToNum())
-	buffer.WriteU16(1) // class
-	return nil
+	if err := buffer.WriteQName(q.Name); err != nil {
+		return err
+	}
+	if err := buffer.WriteU16(q.QType.QueryTypeToNum()); err != nil {
+		return err
+	}
+	return buffer.WriteU16(q.Class())
+}
+
+// Class returns the class of the question, defaulting to ClassIN.
+func (q *DnsQuestion) Class() uint16 {
+	if q.QClass == 0 {
+		return ClassIN
+	}
+	return q.QClass
 }
 
 // DnsRecord represents a DNS resource record.
@@ -232,6 +289,45 @@ type DnsRecord interface {
 	Write(buffer *bytepacketbuffer.BytePacketBuffer) (uint, error)
 	ExtractIPv4() net.IP
 	GetDomain() string
+	GetTTL() uint32
+	// WithTTL returns a copy of the record with its TTL set to ttl.
+	WithTTL(ttl uint32) DnsRecord
+	// WithDomain returns a copy of the record with its owner name set to domain.
+	WithDomain(domain string) DnsRecord
+}
+
+// RecordType returns the type of record.
+func RecordType(record DnsRecord) QueryType {
+	switch record.(type) {
+	case *ARecord:
+		return A
+	case *NSRecord:
+		return NS
+	case *CNAMERecord:
+		return CNAME
+	case *MXRecord:
+		return MX
+	case *AAAARecord:
+		return AAAA
+	case *SOARecord:
+		return SOA
+	case *DNAMERecord:
+		return DNAME
+	case *TXTRecord:
+		return TXT
+	case *PTRRecord:
+		return PTR
+	case *SRVRecord:
+		return SRV
+	case *SVCBRecord:
+		return SVCB
+	case *HTTPSRecord:
+		return HTTPS
+	case *OPTRecord:
+		return OPT
+	default:
+		return UNKNOWN
+	}
 }
 
 // ARecord represents an A DNS record.
@@ -338,6 +434,22 @@ func (a *ARecord) GetDomain() string {
 	return a.Domain
 }
 
+func (a *ARecord) GetTTL() uint32 {
+	return a.TTL
+}
+
+func (a *ARecord) WithTTL(ttl uint32) DnsRecord {
+	record := *a
+	record.TTL = ttl
+	return &record
+}
+
+func (a *ARecord) WithDomain(domain string) DnsRecord {
+	record := *a
+	record.Domain = domain
+	return &record
+}
+
 // NSRecord represents an NS DNS record.
 type NSRecord struct {
 	Domain string
@@ -416,6 +528,22 @@ func (a *NSRecord) GetDomain() string {
 	return a.Domain
 }
 
+func (a *NSRecord) GetTTL() uint32 {
+	return a.TTL
+}
+
+func (a *NSRecord) WithTTL(ttl uint32) DnsRecord {
+	record := *a
+	record.TTL = ttl
+	return &record
+}
+
+func (a *NSRecord) WithDomain(domain string) DnsRecord {
+	record := *a
+	record.Domain = domain
+	return &record
+}
+
 // AAAARecord represents an AAAA DNS record.
 type AAAARecord struct {
 	Domain string
@@ -469,7 +597,7 @@ func (a *AAAARecord) Write(buffer *bytepacketbuffer.BytePacketBuffer) (uint, err
 	if err := buffer.WriteQName(a.Domain); err != nil {
 		return 0, err
 	}
-	if err := buffer.WriteU16(NS.QueryTypeToNum()); err != nil {
+	if err := buffer.WriteU16(AAAA.QueryTypeToNum()); err != nil {
 		return 0, err
 	}
 	if err := buffer.WriteU16(1); err != nil {
@@ -478,11 +606,15 @@ func (a *AAAARecord) Write(buffer *bytepacketbuffer.BytePacketBuffer) (uint, err
 	if err := buffer.WriteU32(a.TTL); err != nil {
 		return 0, err
 	}
-	buffer.WriteU16(16) // data length
+	if err := buffer.WriteU16(16); err != nil {
+		return 0, err
+	} // data length
 
 	// Write the 16 bytes for the IPv6 address
 	for _, octet := range a.Addr.To16() {
-		buffer.WriteU8(octet)
+		if err := buffer.WriteU8(octet); err != nil {
+			return 0, err
+		}
 	}
 
 	return uint(buffer.GetPos() - start_pos), nil
@@ -495,6 +627,22 @@ func (a *AAAARecord) GetDomain() string {
 	return a.Domain
 }
 
+func (a *AAAARecord) GetTTL() uint32 {
+	return a.TTL
+}
+
+func (a *AAAARecord) WithTTL(ttl uint32) DnsRecord {
+	record := *a
+	record.TTL = ttl
+	return &record
+}
+
+func (a *AAAARecord) WithDomain(domain string) DnsRecord {
+	record := *a
+	record.Domain = domain
+	return &record
+}
+
 // AAAARecord represents an AAAA DNS record.
 type MXRecord struct {
 	Domain   string
@@ -590,6 +738,22 @@ func (a *MXRecord) GetDomain() string {
 	return a.Domain
 }
 
+func (a *MXRecord) GetTTL() uint32 {
+	return a.TTL
+}
+
+func (a *MXRecord) WithTTL(ttl uint32) DnsRecord {
+	record := *a
+	record.TTL = ttl
+	return &record
+}
+
+func (a *MXRecord) WithDomain(domain string) DnsRecord {
+	record := *a
+	record.Domain = domain
+	return &record
+}
+
 type CNAMERecord struct {
 	Domain string
 	Host   string
@@ -670,6 +834,270 @@ func (a *CNAMERecord) GetDomain() string {
 	return a.Domain
 }
 
+func (a *CNAMERecord) GetTTL() uint32 {
+	return a.TTL
+}
+
+func (a *CNAMERecord) WithTTL(ttl uint32) DnsRecord {
+	record := *a
+	record.TTL = ttl
+	return &record
+}
+
+func (a *CNAMERecord) WithDomain(domain string) DnsRecord {
+	record := *a
+	record.Domain = domain
+	return &record
+}
+
+// DNAMERecord represents the DNAME DNS record, which redirects every name
+// below Domain to the same name below Target (RFC 6672).
+type DNAMERecord struct {
+	Domain string
+	Target string
+	TTL    uint32
+}
+
+// Read reads DNAMERecord data from the buffer.
+func (d *DNAMERecord) Read(buffer *bytepacketbuffer.BytePacketBuffer) error {
+	// Domain Name
+	if err := buffer.ReadQName(&d.Domain); err != nil {
+		return err
+	}
+	// QueryType
+	if _, err := buffer.ReadU16(); err != nil {
+		return err
+	}
+
+	if _, err := buffer.ReadU16(); err != nil {
+		return err
+	}
+
+	if ttl, err := buffer.ReadU32(); err != nil {
+		return err
+	} else {
+		d.TTL = ttl
+	}
+
+	// data length, ignored
+	if _, err := buffer.ReadU16(); err != nil {
+		return err
+	}
+
+	return buffer.ReadQName(&d.Target)
+}
+
+// Write writes DNAMERecord data to the buffer.
+func (d *DNAMERecord) Write(buffer *bytepacketbuffer.BytePacketBuffer) (uint, error) {
+	start_pos := buffer.GetPos()
+	if err := buffer.WriteQName(d.Domain); err != nil {
+		return 0, err
+	}
+	if err := buffer.WriteU16(DNAME.QueryTypeToNum()); err != nil {
+		return 0, err
+	}
+	if err := buffer.WriteU16(1); err != nil {
+		return 0, err
+	} // class
+	if err := buffer.WriteU32(d.TTL); err != nil {
+		return 0, err
+	}
+
+	pos := buffer.GetPos()
+
+	if err := buffer.WriteU16(0); err != nil {
+		return 0, err
+	}
+
+	// The target must not be compressed, as per RFC 6672 section 2.5.
+	if err := buffer.WriteQNameUncompressed(d.Target); err != nil {
+		return 0, err
+	}
+
+	size := uint16(buffer.GetPos() - (pos + 2))
+	if err := buffer.SetU16(pos, size); err != nil {
+		return 0, err
+	}
+
+	return uint(buffer.GetPos()) - uint(start_pos), nil
+}
+
+// Substitute returns the name name is redirected to, replacing Domain at its
+// end by Target. It reports false when name is not below Domain.
+func (d *DNAMERecord) Substitute(name string) (string, bool) {
+	if !IsSubdomain(name, d.Domain) || EqualNames(name, d.Domain) {
+		return "", false
+	}
+	prefix := name[:len(name)-len(d.Domain)]
+	if d.Domain == "" {
+		prefix += "."
+	}
+	if d.Target == "" {
+		return strings.TrimSuffix(prefix, "."), true
+	}
+	return prefix + d.Target, true
+}
+
+func (a *DNAMERecord) ExtractIPv4() net.IP {
+	return nil
+}
+
+func (a *DNAMERecord) GetDomain() string {
+	return a.Domain
+}
+
+func (a *DNAMERecord) GetTTL() uint32 {
+	return a.TTL
+}
+
+func (a *DNAMERecord) WithTTL(ttl uint32) DnsRecord {
+	record := *a
+	record.TTL = ttl
+	return &record
+}
+
+func (a *DNAMERecord) WithDomain(domain string) DnsRecord {
+	record := *a
+	record.Domain = domain
+	return &record
+}
+
+// SOARecord represents the SOA DNS record marking the start of a zone of authority.
+type SOARecord struct {
+	Domain  string
+	MName   string // primary name server of the zone
+	RName   string // mailbox of the person responsible for the zone
+	Serial  uint32
+	Refresh uint32
+	Retry   uint32
+	Expire  uint32
+	Minimum uint32 // TTL of negative answers from the zone (RFC 2308)
+	TTL     uint32
+}
+
+// Read reads SOARecord data from the buffer.
+func (s *SOARecord) Read(buffer *bytepacketbuffer.BytePacketBuffer) error {
+	// Domain Name
+	if err := buffer.ReadQName(&s.Domain); err != nil {
+		return err
+	}
+	// QueryType
+	if _, err := buffer.ReadU16(); err != nil {
+		return err
+	}
+
+	if _, err := buffer.ReadU16(); err != nil {
+		return err
+	}
+
+	if ttl, err := buffer.ReadU32(); err != nil {
+		return err
+	} else {
+		s.TTL = ttl
+	}
+
+	// data length, ignored
+	if _, err := buffer.ReadU16(); err != nil {
+		return err
+	}
+
+	return s.readData(buffer)
+}
+
+// readData reads the SOA specific part of the record.
+func (s *SOARecord) readData(buffer *bytepacketbuffer.BytePacketBuffer) error {
+	if err := buffer.ReadQName(&s.MName); err != nil {
+		return err
+	}
+	if err := buffer.ReadQName(&s.RName); err != nil {
+		return err
+	}
+	for _, field := range []*uint32{&s.Serial, &s.Refresh, &s.Retry, &s.Expire, &s.Minimum} {
+		val, err := buffer.ReadU32()
+		if err != nil {
+			return err
+		}
+		*field = val
+	}
+	return nil
+}
+
+// Write writes SOARecord data to the buffer.
+func (s *SOARecord) Write(buffer *bytepacketbuffer.BytePacketBuffer) (uint, error) {
+	start_pos := buffer.GetPos()
+	if err := buffer.WriteQName(s.Domain); err != nil {
+		return 0, err
+	}
+	if err := buffer.WriteU16(SOA.QueryTypeToNum()); err != nil {
+		return 0, err
+	}
+	if err := buffer.WriteU16(1); err != nil {
+		return 0, err
+	} // class
+	if err := buffer.WriteU32(s.TTL); err != nil {
+		return 0, err
+	}
+
+	pos := buffer.GetPos()
+
+	if err := buffer.WriteU16(0); err != nil {
+		return 0, err
+	}
+
+	if err := buffer.WriteQName(s.MName); err != nil {
+		return 0, err
+	}
+	if err := buffer.WriteQName(s.RName); err != nil {
+		return 0, err
+	}
+	for _, field := range []uint32{s.Serial, s.Refresh, s.Retry, s.Expire, s.Minimum} {
+		if err := buffer.WriteU32(field); err != nil {
+			return 0, err
+		}
+	}
+
+	size := uint16(buffer.GetPos() - (pos + 2))
+	if err := buffer.SetU16(pos, size); err != nil {
+		return 0, err
+	}
+
+	return uint(buffer.GetPos()) - uint(start_pos), nil
+}
+
+// NegativeTTL returns how long a negative answer from the zone may be cached:
+// the smaller of the TTL of the SOA record and its minimum field, as per
+// RFC 2308 section 5.
+func (s *SOARecord) NegativeTTL() uint32 {
+	if s.Minimum < s.TTL {
+		return s.Minimum
+	}
+	return s.TTL
+}
+
+func (a *SOARecord) ExtractIPv4() net.IP {
+	return nil
+}
+
+func (a *SOARecord) GetDomain() string {
+	return a.Domain
+}
+
+func (a *SOARecord) GetTTL() uint32 {
+	return a.TTL
+}
+
+func (a *SOARecord) WithTTL(ttl uint32) DnsRecord {
+	record := *a
+	record.TTL = ttl
+	return &record
+}
+
+func (a *SOARecord) WithDomain(domain string) DnsRecord {
+	record := *a
+	record.Domain = domain
+	return &record
+}
+
 type UNKNOWNRecord struct {
 	Domain     string
 	QType      uint16
@@ -726,6 +1154,22 @@ func (a *UNKNOWNRecord) GetDomain() string {
 	return a.Domain
 }
 
+func (a *UNKNOWNRecord) GetTTL() uint32 {
+	return a.TTL
+}
+
+func (a *UNKNOWNRecord) WithTTL(ttl uint32) DnsRecord {
+	record := *a
+	record.TTL = ttl
+	return &record
+}
+
+func (a *UNKNOWNRecord) WithDomain(domain string) DnsRecord {
+	record := *a
+	record.Domain = domain
+	return &record
+}
+
 // DnsPacket represents a DNS packet.
 type DnsPacket struct {
 	Header      *DnsHeader    `json:"header"`
@@ -756,7 +1200,7 @@ func ReadDNSRecord(buffer *bytepacketbuffer.BytePacketBuffer) (DnsRecord, error)
 	}
 	qtype := QueryTypeFromNum(qtype_num)
 
-	_, err = buffer.ReadU16()
+	class, err := buffer.ReadU16()
 	if err != nil {
 		return nil, err
 	}
@@ -771,6 +1215,23 @@ func ReadDNSRecord(buffer *bytepacketbuffer.BytePacketBuffer) (DnsRecord, error)
 		return nil, err
 	}
 
+	// However much of the data the record type makes use of, the next
+	// record starts right after it.
+	end := buffer.GetPos() + int(data_len)
+	record, err := readRecordData(buffer, domain, qtype, class, ttl, data_len)
+	if err != nil {
+		return nil, err
+	}
+	if buffer.GetPos() > end {
+		return nil, fmt.Errorf("record of type %d for %q overruns its data length of %d", qtype_num, domain, data_len)
+	}
+	buffer.Seek(end)
+	return record, nil
+}
+
+// readRecordData reads the type specific part of a record of qtype, whose
+// data is data_len bytes long, from the buffer.
+func readRecordData(buffer *bytepacketbuffer.BytePacketBuffer, domain string, qtype QueryType, class uint16, ttl uint32, data_len uint16) (DnsRecord, error) {
 	switch qtype {
 	case A:
 		raw_addr, err := buffer.ReadU32()
@@ -846,12 +1307,24 @@ func ReadDNSRecord(buffer *bytepacketbuffer.BytePacketBuffer) (DnsRecord, error)
 		var cname string
 		buffer.ReadQName(&cname)
 
-		return &NSRecord{
+		return &CNAMERecord{
 			Domain: domain,
 			Host:   cname,
 			TTL:    ttl,
 		}, nil
 
+	case DNAME:
+		var target string
+		if err := buffer.ReadQName(&target); err != nil {
+			return nil, err
+		}
+
+		return &DNAMERecord{
+			Domain: domain,
+			Target: target,
+			TTL:    ttl,
+		}, nil
+
 	case MX:
 		priority, err := buffer.ReadU16()
 		if err != nil {
@@ -867,8 +1340,63 @@ func ReadDNSRecord(buffer *bytepacketbuffer.BytePacketBuffer) (DnsRecord, error)
 			TTL:      ttl,
 		}, nil
 
+	case SOA:
+		soa := &SOARecord{Domain: domain, TTL: ttl}
+		if err := soa.readData(buffer); err != nil {
+			return nil, err
+		}
+		return soa, nil
+
+	case SRV:
+		srv := &SRVRecord{Domain: domain, TTL: ttl}
+		if err := srv.readData(buffer); err != nil {
+			return nil, err
+		}
+		return srv, nil
+
+	case PTR:
+		var ptr string
+		if err := buffer.ReadQName(&ptr); err != nil {
+			return nil, err
+		}
+
+		return &PTRRecord{
+			Domain: domain,
+			Host:   ptr,
+			TTL:    ttl,
+		}, nil
+
+	case TXT:
+		values, err := readCharacterStrings(buffer, data_len)
+		if err != nil {
+			return nil, err
+		}
+		return &TXTRecord{
+			Domain:  domain,
+			Strings: values,
+			TTL:     ttl,
+		}, nil
+
+	case SVCB, HTTPS:
+		svcb := SVCBRecord{Domain: domain, TTL: ttl}
+		if err := svcb.readData(buffer, data_len); err != nil {
+			return nil, err
+		}
+		if qtype == HTTPS {
+			return &HTTPSRecord{SVCBRecord: svcb}, nil
+		}
+		return &svcb, nil
+
+	case OPT:
+		options, err := readEDNSOptions(buffer, data_len)
+		if err != nil {
+			return nil, err
+		}
+		opt := &OPTRecord{UDPPayloadSize: class, Options: options}
+		opt.setTTL(ttl)
+		return opt, nil
+
 	default: // UNKNOWN
-		buffer.Step(int(data_len))
 		return &UNKNOWNRecord{
 			Domain:     domain,
 			QType:      UNKNOWN.QueryTypeToNum(),
@@ -920,6 +1448,7 @@ func FromBuffer(buffer *bytepacketbuffer.BytePacketBuffer) (*DnsPacket, error) {
 
 // Write writes the DNS packet to the buffer.
 func (p *DnsPacket) Write(buffer *bytepacketbuffer.BytePacketBuffer) error {
+	start_pos := buffer.GetPos()
 	p.Header.Questions = uint16(len(p.Questions))
 	p.Header.Answers = uint16(len(p.Answers))
 	p.Header.AuthoritativeEntries = uint16(len(p.Authorities))
@@ -935,106 +1464,40 @@ func (p *DnsPacket) Write(buffer *bytepacketbuffer.BytePacketBuffer) error {
 		}
 	}
 
-	for _, record := range p.Answers {
-		if _, err := record.Write(buffer); err != nil {
-			return err
-		}
-	}
-	for _, record := range p.Authorities {
-		if _, err := record.Write(buffer); err != nil {
-			return err
-		}
-	}
-	for _, record := range p.Resources {
-		if _, err := record.Write(buffer); err != nil {
-			return err
-		}
-	}
-
-	return nil
-}
-
-// It's useful to be able to pick a random A record from a packet. When we
-// get multiple IP's for a single name, it doesn't matter which one we
-// choose, so in those cases we can now pick one at random.
-// GetRandomA returns a random IPv4 address (A record) from the DNS packet's list of answers.
-func (p *DnsPacket) GetRandomA() net.IP {
-	for _, record := range p.Answers {
-		// if a, ok := record.(DnsRecord); ok {
-		// Only A records return the address and others return nil
-		switch record.(type) {
-		case *ARecord:
-			addr := record.ExtractIPv4()
-			if addr != nil {
-				return addr
+	// Records which can't be written, like those of unknown types, are
+	// skipped. The counts in the header are fixed up to match, which are
+	// found after the ID, the flags and the question count.
+	counts := []*uint16{&p.Header.Answers, &p.Header.AuthoritativeEntries, &p.Header.ResourceEntries}
+	for i, section := range [][]DnsRecord{p.Answers, p.Authorities, p.Resources} {
+		written := uint16(0)
+		for _, record := range section {
+			size, err := record.Write(buffer)
+			if err != nil {
+				return err
 			}
-		default:
-			continue
-		}
-	}
-	return nil // Return nil if no IPv4 address is found
-}
-
-// GetNS returns an iterator over all name servers in the authorities section,
-// represented as (domain, host) tuples.
-func (p *DnsPacket) GetNS(qname string) <-chan struct {
-	Domain string
-	Host   string
-} {
-	resultChan := make(chan struct {
-		Domain string
-		Host   string
-	})
-
-	go func() {
-		defer close(resultChan)
-
-		for _, record := range p.Authorities {
-			if strings.HasSuffix(qname, record.GetDomain()) {
-				switch nsRecord := record.(type) {
-				case *NSRecord:
-					resultChan <- struct {
-						Domain string
-						Host   string
-					}{
-						Domain: nsRecord.Domain,
-						Host:   nsRecord.Host,
-					}
-				}
+			if size > 0 {
+				written++
 			}
 		}
-	}()
-
-	return resultChan
-}
 
-// GetResolvedNS returns the resolved IP for an NS record if possible.
-// We'll use the fact that name servers often bundle the corresponding
-// A records when replying to an NS query to implement a function that
-// returns the actual IP for an NS record if possible.
-func (p *DnsPacket) GetResolvedNS(qname string) net.IP {
-	for ns := range p.GetNS(qname) {
-		// Looking if there are any additional records sent so that we don't have to perform second lookup.
-		for _, record := range p.Resources {
-			if aRecord, ok := record.(*ARecord); ok && aRecord.Domain == ns.Host {
-				return aRecord.Addr
+		if written != *counts[i] {
+			*counts[i] = written
+			if err := buffer.SetU16(start_pos+6+2*i, written); err != nil {
+				return err
 			}
 		}
 	}
-	// no additional A records sent. 
-	return nil // Return nil for no match
+
+	return nil
 }
 
-// / However, not all name servers are as that nice. In certain cases there won't
-// / be any A records in the additional section, and we'll have to perform *another*
-// / lookup in the midst. For this, we introduce a method for returning the host
-// / name of an appropriate name server.
-// GetUnresolvedNS returns the host name of an appropriate name server.
-func (p *DnsPacket) GetUnresolvedNS(qname string) string {
-	for ns := range p.GetNS(qname) {
-		fmt.Printf("ns from unresolved NS: %+v", ns)
-		return ns.Host
+// GetSOA returns the SOA record in the authority section, which comes with
+// negative answers, or nil if there is none.
+func (p *DnsPacket) GetSOA() *SOARecord {
+	for _, record := range p.Authorities {
+		if soa, ok := record.(*SOARecord); ok {
+			return soa
+		}
 	}
-
-	return "" // Return an empty string for no match
+	return nil
 }
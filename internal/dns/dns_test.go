@@ -0,0 +1,54 @@
+package dns
+
+import (
+	"net"
+	"testing"
+
+	"github.com/sadityakumar9211/go-res/pkg/bytepacketbuffer"
+)
+
+func TestReadDNSRecordDataLength(t *testing.T) {
+	// Two A records for "a", the first with rdata padded to rdlength bytes.
+	record := func(rdlength byte, rdata ...byte) []byte {
+		return append([]byte{1, 'a', 0, 0, 1, 0, 1, 0, 0, 0, 60, 0, rdlength}, rdata...)
+	}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr bool
+	}{
+		{"exact", append(record(4, 192, 0, 2, 1), record(4, 192, 0, 2, 2)...), false},
+		{"padded", append(record(6, 192, 0, 2, 1, 0xee, 0xee), record(4, 192, 0, 2, 2)...), false},
+		{"short", append(record(2, 192, 0), record(4, 192, 0, 2, 2)...), true},
+	}
+
+	for _, test := range tests {
+		buffer := bytepacketbuffer.FromBytes(test.data)
+		first, err := ReadDNSRecord(&buffer)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%s: read %v, want error", test.name, first)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		second, err := ReadDNSRecord(&buffer)
+		if err != nil {
+			t.Errorf("%s: reading the second record: %v", test.name, err)
+			continue
+		}
+
+		for i, want := range []struct {
+			record DnsRecord
+			addr   net.IP
+		}{{first, net.IPv4(192, 0, 2, 1)}, {second, net.IPv4(192, 0, 2, 2)}} {
+			if got := want.record.ExtractIPv4(); !got.Equal(want.addr) {
+				t.Errorf("%s: record %d has address %v, want %v", test.name, i, got, want.addr)
+			}
+		}
+	}
+}
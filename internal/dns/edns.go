@@ -0,0 +1,217 @@
+package dns
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/sadityakumar9211/go-res/pkg/bytepacketbuffer"
+)
+
+// ExtendedRCodeBADVERS is the extended result code sent back when a query
+// uses an EDNS version we don't implement.
+const ExtendedRCodeBADVERS uint8 = 1
+
+// EDNSOption is a single option carried in the data of an OPT record.
+type EDNSOption struct {
+	Code uint16
+	Data []byte
+}
+
+// OPTRecord represents the EDNS(0) OPT pseudo-record (RFC 6891). It lives in
+// the additional section and reuses the class and TTL fields of a regular
+// record to carry the sender's UDP payload size and extended flags.
+type OPTRecord struct {
+	UDPPayloadSize uint16
+	ExtendedRCode  uint8
+	Version        uint8
+	DNSSECOK       bool
+	Options        []EDNSOption
+}
+
+// NewOPTRecord creates a version 0 OPT record advertising size as the UDP payload size.
+func NewOPTRecord(size uint16) *OPTRecord {
+	return &OPTRecord{UDPPayloadSize: size}
+}
+
+// ttl packs the extended result code, version and flags the way they are
+// carried in the TTL field.
+func (o *OPTRecord) ttl() uint32 {
+	ttl := uint32(o.ExtendedRCode)<<24 | uint32(o.Version)<<16
+	if o.DNSSECOK {
+		ttl |= 0x8000
+	}
+	return ttl
+}
+
+// setTTL unpacks the extended result code, version and flags from the TTL field.
+func (o *OPTRecord) setTTL(ttl uint32) {
+	o.ExtendedRCode = uint8(ttl >> 24)
+	o.Version = uint8(ttl >> 16)
+	o.DNSSECOK = ttl&0x8000 > 0
+}
+
+// Read reads OPTRecord data from the buffer.
+func (o *OPTRecord) Read(buffer *bytepacketbuffer.BytePacketBuffer) error {
+	// Domain Name, always the root
+	var domain string
+	if err := buffer.ReadQName(&domain); err != nil {
+		return err
+	}
+	// QueryType
+	if _, err := buffer.ReadU16(); err != nil {
+		return err
+	}
+
+	// The class holds the UDP payload size
+	if size, err := buffer.ReadU16(); err != nil {
+		return err
+	} else {
+		o.UDPPayloadSize = size
+	}
+
+	if ttl, err := buffer.ReadU32(); err != nil {
+		return err
+	} else {
+		o.setTTL(ttl)
+	}
+
+	dataLength, err := buffer.ReadU16()
+	if err != nil {
+		return err
+	}
+
+	options, err := readEDNSOptions(buffer, dataLength)
+	if err != nil {
+		return err
+	}
+	o.Options = options
+
+	return nil
+}
+
+// readEDNSOptions reads the options making up length bytes of OPT record data.
+func readEDNSOptions(buffer *bytepacketbuffer.BytePacketBuffer, length uint16) ([]EDNSOption, error) {
+	options := make([]EDNSOption, 0)
+	end := buffer.GetPos() + int(length)
+
+	for buffer.GetPos() < end {
+		if buffer.GetPos()+4 > end {
+			return nil, errors.New("EDNS option header runs past the end of the OPT record")
+		}
+		code, err := buffer.ReadU16()
+		if err != nil {
+			return nil, err
+		}
+		optionLength, err := buffer.ReadU16()
+		if err != nil {
+			return nil, err
+		}
+		if buffer.GetPos()+int(optionLength) > end {
+			return nil, fmt.Errorf("EDNS option of %d bytes runs past the end of the OPT record", optionLength)
+		}
+		data, err := buffer.GetRange(buffer.GetPos(), int(optionLength))
+		if err != nil {
+			return nil, err
+		}
+		buffer.Step(int(optionLength))
+
+		options = append(options, EDNSOption{Code: code, Data: append([]byte(nil), data...)})
+	}
+
+	return options, nil
+}
+
+// Write writes OPTRecord data to the buffer.
+func (o *OPTRecord) Write(buffer *bytepacketbuffer.BytePacketBuffer) (uint, error) {
+	start_pos := buffer.GetPos()
+
+	// The owner of an OPT record is always the root, an empty name.
+	if err := buffer.WriteU8(0); err != nil {
+		return 0, err
+	}
+	if err := buffer.WriteU16(OPT.QueryTypeToNum()); err != nil {
+		return 0, err
+	}
+	if err := buffer.WriteU16(o.UDPPayloadSize); err != nil {
+		return 0, err
+	}
+	if err := buffer.WriteU32(o.ttl()); err != nil {
+		return 0, err
+	}
+
+	pos := buffer.GetPos()
+
+	if err := buffer.WriteU16(0); err != nil {
+		return 0, err
+	} // data length
+
+	for _, option := range o.Options {
+		if err := buffer.WriteU16(option.Code); err != nil {
+			return 0, err
+		}
+		if err := buffer.WriteU16(uint16(len(option.Data))); err != nil {
+			return 0, err
+		}
+		for _, b := range option.Data {
+			if err := buffer.WriteU8(b); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	size := uint16(buffer.GetPos() - (pos + 2))
+	if err := buffer.SetU16(pos, size); err != nil {
+		return 0, err
+	}
+
+	return uint(buffer.GetPos() - start_pos), nil
+}
+
+func (o *OPTRecord) ExtractIPv4() net.IP {
+	return nil
+}
+
+func (o *OPTRecord) GetDomain() string {
+	return ""
+}
+
+// GetTTL returns 0, as the TTL field of an OPT record doesn't hold a TTL.
+func (o *OPTRecord) GetTTL() uint32 {
+	return 0
+}
+
+// WithTTL returns a copy of the record, unchanged, as OPT records are never cached.
+func (o *OPTRecord) WithTTL(ttl uint32) DnsRecord {
+	record := *o
+	return &record
+}
+
+// WithDomain returns a copy of the record, unchanged, as OPT records are always
+// owned by the root.
+func (o *OPTRecord) WithDomain(domain string) DnsRecord {
+	record := *o
+	return &record
+}
+
+// GetOPT returns the OPT record of the packet, or nil if the sender doesn't use EDNS.
+func (p *DnsPacket) GetOPT() *OPTRecord {
+	for _, record := range p.Resources {
+		if opt, ok := record.(*OPTRecord); ok {
+			return opt
+		}
+	}
+	return nil
+}
+
+// RemoveOPT drops any OPT record from the additional section. OPT records only
+// apply to a single hop, so they must not be passed on to another client.
+func (p *DnsPacket) RemoveOPT() {
+	resources := make([]DnsRecord, 0, len(p.Resources))
+	for _, record := range p.Resources {
+		if _, ok := record.(*OPTRecord); !ok {
+			resources = append(resources, record)
+		}
+	}
+	p.Resources = resources
+}
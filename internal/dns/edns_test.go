@@ -0,0 +1,44 @@
+package dns
+
+import (
+	"testing"
+
+	"github.com/sadityakumar9211/go-res/pkg/bytepacketbuffer"
+)
+
+func TestReadEDNSOptionsBounds(t *testing.T) {
+	tests := []struct {
+		name    string
+		rdata   []byte
+		wantErr bool
+	}{
+		{"option within rdata", []byte{0, 10, 0, 2, 0xab, 0xcd}, false},
+		{"option length past rdata", []byte{0, 10, 0, 4, 0xab, 0xcd}, true},
+		{"option header past rdata", []byte{0, 10, 0}, true},
+	}
+
+	for _, test := range tests {
+		// The OPT record is followed by more data, which an option running
+		// past its RDLENGTH would take for its own.
+		data := []byte{0, 0, 41, 0x04, 0xd0, 0, 0, 0, 0, 0, byte(len(test.rdata))}
+		data = append(data, test.rdata...)
+		data = append(data, 0xee, 0xee, 0xee, 0xee)
+		buffer := bytepacketbuffer.FromBytes(data)
+
+		record, err := ReadDNSRecord(&buffer)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%s: read %v, want error", test.name, record)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		opt, ok := record.(*OPTRecord)
+		if !ok || len(opt.Options) != 1 || len(opt.Options[0].Data) != 2 {
+			t.Errorf("%s: read %#v, want one option of 2 bytes", test.name, record)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package dns
+
+import "strings"
+
+// IsSubdomain reports whether name is zone itself or a name below it. Names
+// are compared case-insensitively and on label boundaries, so "badexample.com"
+// is not a subdomain of "example.com". Every name is below the root zone "".
+func IsSubdomain(name string, zone string) bool {
+	name = strings.TrimSuffix(name, ".")
+	zone = strings.TrimSuffix(zone, ".")
+	if zone == "" {
+		return true
+	}
+	if len(name) == len(zone) {
+		return EqualNames(name, zone)
+	}
+	if len(name) < len(zone) || name[len(name)-len(zone)-1] != '.' {
+		return false
+	}
+	return EqualNames(name[len(name)-len(zone):], zone)
+}
+
+// EqualNames reports whether the names a and b are equal. As per RFC 4343,
+// only the ASCII letters are compared case-insensitively; every other byte,
+// whether it is part of a valid UTF-8 sequence or not, must match exactly.
+// Unlike strings.EqualFold, this doesn't treat the Kelvin sign as a 'k'.
+func EqualNames(a, b string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := 0; i < len(a); i++ {
+		if lowerASCII(a[i]) != lowerASCII(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// CanonicalName returns name with its ASCII letters in lower case, leaving
+// every other byte as is, so that names equal by EqualNames map to the same
+// string, e.g. as a map or cache key.
+func CanonicalName(name string) string {
+	for i := 0; i < len(name); i++ {
+		if lowerASCII(name[i]) != name[i] {
+			lowered := []byte(name)
+			for j := i; j < len(lowered); j++ {
+				lowered[j] = lowerASCII(lowered[j])
+			}
+			return string(lowered)
+		}
+	}
+	return name
+}
+
+// lowerASCII returns ch in lower case if it is an ASCII upper case letter.
+func lowerASCII(ch byte) byte {
+	if 'A' <= ch && ch <= 'Z' {
+		return ch + 'a' - 'A'
+	}
+	return ch
+}
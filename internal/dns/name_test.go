@@ -0,0 +1,84 @@
+package dns
+
+import "testing"
+
+func TestIsSubdomain(t *testing.T) {
+	tests := []struct {
+		name string
+		zone string
+		want bool
+	}{
+		{"example.com", "example.com", true},
+		{"www.example.com", "example.com", true},
+		{"a.b.example.com", "example.com", true},
+		{"badexample.com", "example.com", false},
+		{"example.com", "www.example.com", false},
+		{"example.org", "example.com", false},
+		{"com", "example.com", false},
+		{"WWW.Example.COM", "example.com", true},
+		{"www.example.com", "EXAMPLE.com", true},
+		{"www.example.com.", "example.com", true},
+		{"www.example.com", "example.com.", true},
+		{"example.com.", "example.com.", true},
+		{"example.com", "", true},
+		{"example.com", ".", true},
+		{"", "", true},
+		{"", "com", false},
+		{"www.\u212Aexample.com", "kexample.com", false},
+		{"www.\xffexample.com", "\xffEXAMPLE.com", true},
+		{"www.\xffexample.com", "\xfeexample.com", false},
+	}
+
+	for _, test := range tests {
+		if got := IsSubdomain(test.name, test.zone); got != test.want {
+			t.Errorf("IsSubdomain(%q, %q) = %v, want %v", test.name, test.zone, got, test.want)
+		}
+	}
+}
+
+func TestEqualNames(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"example.com", "example.com", true},
+		{"WWW.Example.COM", "www.example.com", true},
+		{"example.com", "example.org", false},
+		{"example.com", "example.co", false},
+		// U+212A KELVIN SIGN folds to 'k' in Unicode, but not in DNS.
+		{"\u212A.example", "k.example", false},
+		{"\u212A.example", "K.example", false},
+		{"\u212A.example", "\u212A.example", true},
+		// Bytes which aren't valid UTF-8 are compared as they are.
+		{"\xff.example", "\xff.example", true},
+		{"\xff.example", "\xfe.example", false},
+		{"\xc1.example", "\xe1.example", false},
+		{"\xffA.example", "\xffa.example", true},
+	}
+
+	for _, test := range tests {
+		if got := EqualNames(test.a, test.b); got != test.want {
+			t.Errorf("EqualNames(%q, %q) = %v, want %v", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestCanonicalName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"example.com", "example.com"},
+		{"WWW.Example.COM", "www.example.com"},
+		{"\u212A.example", "\u212A.example"},
+		{"\xffA.EXAMPLE", "\xffa.example"},
+		{"\xc1.example", "\xc1.example"},
+		{"", ""},
+	}
+
+	for _, test := range tests {
+		if got := CanonicalName(test.name); got != test.want {
+			t.Errorf("CanonicalName(%q) = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
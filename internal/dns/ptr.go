@@ -0,0 +1,181 @@
+package dns
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/sadityakumar9211/go-res/pkg/bytepacketbuffer"
+)
+
+// Reverse lookups are done for names below these zones (RFC 1035 section 3.5
+// and RFC 3596 section 2.5).
+const (
+	ReverseZoneIPv4 = "in-addr.arpa"
+	ReverseZoneIPv6 = "ip6.arpa"
+)
+
+// PTRRecord represents the PTR DNS record, pointing from the reverse name of
+// an address to the host name it belongs to.
+type PTRRecord struct {
+	Domain string
+	Host   string
+	TTL    uint32
+}
+
+// Read reads PTRRecord data from the buffer.
+func (p *PTRRecord) Read(buffer *bytepacketbuffer.BytePacketBuffer) error {
+	// Domain Name
+	if err := buffer.ReadQName(&p.Domain); err != nil {
+		return err
+	}
+	// QueryType
+	if _, err := buffer.ReadU16(); err != nil {
+		return err
+	}
+
+	if _, err := buffer.ReadU16(); err != nil {
+		return err
+	}
+
+	if ttl, err := buffer.ReadU32(); err != nil {
+		return err
+	} else {
+		p.TTL = ttl
+	}
+
+	// data length, ignored
+	if _, err := buffer.ReadU16(); err != nil {
+		return err
+	}
+
+	return buffer.ReadQName(&p.Host)
+}
+
+// Write writes PTRRecord data to the buffer.
+func (p *PTRRecord) Write(buffer *bytepacketbuffer.BytePacketBuffer) (uint, error) {
+	start_pos := buffer.GetPos()
+	if err := buffer.WriteQName(p.Domain); err != nil {
+		return 0, err
+	}
+	if err := buffer.WriteU16(PTR.QueryTypeToNum()); err != nil {
+		return 0, err
+	}
+	if err := buffer.WriteU16(1); err != nil {
+		return 0, err
+	} // class
+	if err := buffer.WriteU32(p.TTL); err != nil {
+		return 0, err
+	}
+
+	pos := buffer.GetPos()
+
+	if err := buffer.WriteU16(0); err != nil {
+		return 0, err
+	}
+
+	if err := buffer.WriteQName(p.Host); err != nil {
+		return 0, err
+	}
+
+	size := uint16(buffer.GetPos() - (pos + 2))
+	if err := buffer.SetU16(pos, size); err != nil {
+		return 0, err
+	}
+
+	return uint(buffer.GetPos() - start_pos), nil
+}
+
+func (p *PTRRecord) ExtractIPv4() net.IP {
+	return nil
+}
+
+func (p *PTRRecord) GetDomain() string {
+	return p.Domain
+}
+
+func (p *PTRRecord) GetTTL() uint32 {
+	return p.TTL
+}
+
+func (p *PTRRecord) WithTTL(ttl uint32) DnsRecord {
+	record := *p
+	record.TTL = ttl
+	return &record
+}
+
+func (p *PTRRecord) WithDomain(domain string) DnsRecord {
+	record := *p
+	record.Domain = domain
+	return &record
+}
+
+// ReverseName returns the name to look up PTR records for ip at: its octets
+// in reverse order below in-addr.arpa for an IPv4 address, and its nibbles in
+// reverse order below ip6.arpa for an IPv6 address.
+func ReverseName(ip net.IP) (string, error) {
+	if ip4 := ip.To4(); ip4 != nil {
+		labels := make([]string, 0, net.IPv4len+1)
+		for i := net.IPv4len - 1; i >= 0; i-- {
+			labels = append(labels, strconv.Itoa(int(ip4[i])))
+		}
+		return strings.Join(append(labels, ReverseZoneIPv4), "."), nil
+	}
+
+	ip6 := ip.To16()
+	if ip6 == nil {
+		return "", fmt.Errorf("invalid IP address %v", ip)
+	}
+	const hexDigits = "0123456789abcdef"
+	labels := make([]string, 0, 2*net.IPv6len+1)
+	for i := net.IPv6len - 1; i >= 0; i-- {
+		labels = append(labels, string(hexDigits[ip6[i]&0x0F]), string(hexDigits[ip6[i]>>4]))
+	}
+	return strings.Join(append(labels, ReverseZoneIPv6), "."), nil
+}
+
+// ParseReverseName returns the address name is the reverse name of, see
+// ReverseName. Only names for complete addresses are accepted.
+func ParseReverseName(name string) (net.IP, error) {
+	name = strings.TrimSuffix(name, ".")
+
+	if IsSubdomain(name, ReverseZoneIPv4) {
+		labels := bytepacketbuffer.SplitDNSName(name[:len(name)-len(ReverseZoneIPv4)])
+		labels = labels[:len(labels)-1] // the empty label before the zone
+		if len(labels) != net.IPv4len {
+			return nil, fmt.Errorf("%q is not the reverse name of an IPv4 address", name)
+		}
+
+		ip := make(net.IP, net.IPv4len)
+		for i, label := range labels {
+			octet, err := strconv.ParseUint(label, 10, 8)
+			if err != nil || (len(label) > 1 && label[0] == '0') {
+				return nil, fmt.Errorf("%q is not the reverse name of an IPv4 address", name)
+			}
+			ip[net.IPv4len-1-i] = byte(octet)
+		}
+		return ip, nil
+	}
+
+	if IsSubdomain(name, ReverseZoneIPv6) {
+		labels := bytepacketbuffer.SplitDNSName(name[:len(name)-len(ReverseZoneIPv6)])
+		labels = labels[:len(labels)-1] // the empty label before the zone
+		if len(labels) != 2*net.IPv6len {
+			return nil, fmt.Errorf("%q is not the reverse name of an IPv6 address", name)
+		}
+
+		ip := make(net.IP, net.IPv6len)
+		for i, label := range labels {
+			nibble, err := strconv.ParseUint(label, 16, 4)
+			if err != nil || len(label) != 1 {
+				return nil, fmt.Errorf("%q is not the reverse name of an IPv6 address", name)
+			}
+			ip[net.IPv6len-1-i/2] |= byte(nibble) << (4 * (i % 2))
+		}
+		return ip, nil
+	}
+
+	return nil, errors.New("not a reverse name: " + name)
+}
@@ -0,0 +1,202 @@
+package dns
+
+import (
+	"math/rand"
+	"net"
+	"sort"
+
+	"github.com/sadityakumar9211/go-res/pkg/bytepacketbuffer"
+)
+
+// SRVRecord represents the SRV DNS record (RFC 2782), locating the servers
+// for a service: Domain is named like _service._proto.name, and each record
+// points to a Target host and Port to connect to.
+type SRVRecord struct {
+	Domain   string
+	Priority uint16
+	Weight   uint16
+	Port     uint16
+	Target   string
+	TTL      uint32
+}
+
+// Read reads SRVRecord data from the buffer.
+func (s *SRVRecord) Read(buffer *bytepacketbuffer.BytePacketBuffer) error {
+	// Domain Name
+	if err := buffer.ReadQName(&s.Domain); err != nil {
+		return err
+	}
+	// QueryType
+	if _, err := buffer.ReadU16(); err != nil {
+		return err
+	}
+
+	if _, err := buffer.ReadU16(); err != nil {
+		return err
+	}
+
+	if ttl, err := buffer.ReadU32(); err != nil {
+		return err
+	} else {
+		s.TTL = ttl
+	}
+
+	// data length, ignored
+	if _, err := buffer.ReadU16(); err != nil {
+		return err
+	}
+
+	return s.readData(buffer)
+}
+
+// readData reads the priority, weight, port and target of the record.
+func (s *SRVRecord) readData(buffer *bytepacketbuffer.BytePacketBuffer) error {
+	var err error
+	if s.Priority, err = buffer.ReadU16(); err != nil {
+		return err
+	}
+	if s.Weight, err = buffer.ReadU16(); err != nil {
+		return err
+	}
+	if s.Port, err = buffer.ReadU16(); err != nil {
+		return err
+	}
+	return buffer.ReadQName(&s.Target)
+}
+
+// Write writes SRVRecord data to the buffer.
+func (s *SRVRecord) Write(buffer *bytepacketbuffer.BytePacketBuffer) (uint, error) {
+	start_pos := buffer.GetPos()
+	if err := buffer.WriteQName(s.Domain); err != nil {
+		return 0, err
+	}
+	if err := buffer.WriteU16(SRV.QueryTypeToNum()); err != nil {
+		return 0, err
+	}
+	if err := buffer.WriteU16(1); err != nil {
+		return 0, err
+	} // class
+	if err := buffer.WriteU32(s.TTL); err != nil {
+		return 0, err
+	}
+
+	pos := buffer.GetPos()
+
+	if err := buffer.WriteU16(0); err != nil {
+		return 0, err
+	}
+
+	if err := buffer.WriteU16(s.Priority); err != nil {
+		return 0, err
+	}
+	if err := buffer.WriteU16(s.Weight); err != nil {
+		return 0, err
+	}
+	if err := buffer.WriteU16(s.Port); err != nil {
+		return 0, err
+	}
+	// The target must not be compressed, as per RFC 2782.
+	if err := buffer.WriteQNameUncompressed(s.Target); err != nil {
+		return 0, err
+	}
+
+	size := uint16(buffer.GetPos() - (pos + 2))
+	if err := buffer.SetU16(pos, size); err != nil {
+		return 0, err
+	}
+
+	return uint(buffer.GetPos() - start_pos), nil
+}
+
+func (s *SRVRecord) ExtractIPv4() net.IP {
+	return nil
+}
+
+func (s *SRVRecord) GetDomain() string {
+	return s.Domain
+}
+
+func (s *SRVRecord) GetTTL() uint32 {
+	return s.TTL
+}
+
+func (s *SRVRecord) WithTTL(ttl uint32) DnsRecord {
+	record := *s
+	record.TTL = ttl
+	return &record
+}
+
+func (s *SRVRecord) WithDomain(domain string) DnsRecord {
+	record := *s
+	record.Domain = domain
+	return &record
+}
+
+// OrderSRV returns the order in which a client should try the servers of
+// records, as described in RFC 2782: lowest priority first, and within the
+// same priority in a random order where records with a higher weight are
+// more likely to come first. Records with the target "." say the service is
+// not available and are left out, so the result may be empty.
+// records is not modified.
+func OrderSRV(records []*SRVRecord) []*SRVRecord {
+	available := make([]*SRVRecord, 0, len(records))
+	for _, record := range records {
+		if record.Target != "" {
+			available = append(available, record)
+		}
+	}
+	sort.SliceStable(available, func(i, j int) bool {
+		return available[i].Priority < available[j].Priority
+	})
+
+	ordered := make([]*SRVRecord, 0, len(available))
+	for start := 0; start < len(available); {
+		end := start
+		for end < len(available) && available[end].Priority == available[start].Priority {
+			end++
+		}
+		ordered = append(ordered, orderByWeight(available[start:end])...)
+		start = end
+	}
+	return ordered
+}
+
+// orderByWeight orders records of the same priority by repeated weighted
+// random selection. Records of weight 0 are put first in the list the
+// selection runs over, which gives them a small chance to be picked early.
+func orderByWeight(records []*SRVRecord) []*SRVRecord {
+	remaining := make([]*SRVRecord, 0, len(records))
+	for _, record := range records {
+		if record.Weight == 0 {
+			remaining = append(remaining, record)
+		}
+	}
+	for _, record := range records {
+		if record.Weight != 0 {
+			remaining = append(remaining, record)
+		}
+	}
+
+	ordered := make([]*SRVRecord, 0, len(records))
+	for len(remaining) > 0 {
+		total := 0
+		for _, record := range remaining {
+			total += int(record.Weight)
+		}
+
+		// Pick the first record whose running sum of weights reaches a random
+		// number between 0 and the total, both inclusive.
+		pick, sum := rand.Intn(total+1), 0
+		i := 0
+		for ; i < len(remaining)-1; i++ {
+			sum += int(remaining[i].Weight)
+			if sum >= pick {
+				break
+			}
+		}
+
+		ordered = append(ordered, remaining[i])
+		remaining = append(remaining[:i], remaining[i+1:]...)
+	}
+	return ordered
+}
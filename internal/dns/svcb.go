@@ -0,0 +1,611 @@
+package dns
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sadityakumar9211/go-res/pkg/bytepacketbuffer"
+)
+
+// SvcParamKey identifies a service parameter of an SVCB or HTTPS record.
+type SvcParamKey uint16
+
+// Service parameter keys defined in RFC 9460 section 14.3.2.
+const (
+	SvcParamMandatory     SvcParamKey = 0
+	SvcParamALPN          SvcParamKey = 1
+	SvcParamNoDefaultALPN SvcParamKey = 2
+	SvcParamPort          SvcParamKey = 3
+	SvcParamIPv4Hint      SvcParamKey = 4
+	SvcParamECH           SvcParamKey = 5
+	SvcParamIPv6Hint      SvcParamKey = 6
+)
+
+var svcParamKeyNames = map[SvcParamKey]string{
+	SvcParamMandatory:     "mandatory",
+	SvcParamALPN:          "alpn",
+	SvcParamNoDefaultALPN: "no-default-alpn",
+	SvcParamPort:          "port",
+	SvcParamIPv4Hint:      "ipv4hint",
+	SvcParamECH:           "ech",
+	SvcParamIPv6Hint:      "ipv6hint",
+}
+
+// String returns the name of the key in presentation format, keyNNNNN for
+// keys without a name.
+func (k SvcParamKey) String() string {
+	if name, ok := svcParamKeyNames[k]; ok {
+		return name
+	}
+	return "key" + strconv.Itoa(int(k))
+}
+
+// SvcParam is a service parameter of an SVCB or HTTPS record. Every key has a
+// type of its own, holding the parsed value; keys we don't know are kept as
+// SvcUnknown.
+type SvcParam interface {
+	Key() SvcParamKey
+	// String returns the value in presentation format, or "" for a
+	// parameter without a value.
+	String() string
+
+	pack() ([]byte, error)
+	unpack(data []byte) error
+}
+
+// SvcMandatory lists the keys a client must understand to use the record.
+type SvcMandatory struct {
+	Keys []SvcParamKey
+}
+
+func (p *SvcMandatory) Key() SvcParamKey { return SvcParamMandatory }
+
+func (p *SvcMandatory) String() string {
+	names := make([]string, 0, len(p.Keys))
+	for _, key := range p.Keys {
+		names = append(names, key.String())
+	}
+	return strings.Join(names, ",")
+}
+
+func (p *SvcMandatory) pack() ([]byte, error) {
+	data := make([]byte, 0, 2*len(p.Keys))
+	for _, key := range p.Keys {
+		data = append(data, byte(key>>8), byte(key))
+	}
+	return data, nil
+}
+
+func (p *SvcMandatory) unpack(data []byte) error {
+	if len(data) == 0 || len(data)%2 != 0 {
+		return fmt.Errorf("mandatory of %d bytes", len(data))
+	}
+	p.Keys = make([]SvcParamKey, 0, len(data)/2)
+	for i := 0; i < len(data); i += 2 {
+		key := SvcParamKey(data[i])<<8 | SvcParamKey(data[i+1])
+		if len(p.Keys) > 0 && key <= p.Keys[len(p.Keys)-1] {
+			return errors.New("mandatory keys out of order")
+		}
+		p.Keys = append(p.Keys, key)
+	}
+	return nil
+}
+
+// SvcALPN lists the protocols the service supports, as ALPN protocol IDs
+// like "h2" and "h3".
+type SvcALPN struct {
+	IDs []string
+}
+
+func (p *SvcALPN) Key() SvcParamKey { return SvcParamALPN }
+
+// String returns the IDs separated by commas. Commas and backslashes within
+// an ID are escaped with a backslash, as per RFC 9460 appendix A.1.
+func (p *SvcALPN) String() string {
+	ids := make([]string, 0, len(p.IDs))
+	for _, id := range p.IDs {
+		id = strings.ReplaceAll(id, `\`, `\\`)
+		ids = append(ids, strings.ReplaceAll(id, ",", `\,`))
+	}
+	return strings.Join(ids, ",")
+}
+
+func (p *SvcALPN) pack() ([]byte, error) {
+	data := make([]byte, 0)
+	for _, id := range p.IDs {
+		if id == "" || len(id) > maxCharacterString {
+			return nil, fmt.Errorf("alpn ID of %d bytes", len(id))
+		}
+		data = append(data, byte(len(id)))
+		data = append(data, id...)
+	}
+	return data, nil
+}
+
+func (p *SvcALPN) unpack(data []byte) error {
+	if len(data) == 0 {
+		return errors.New("empty alpn")
+	}
+	p.IDs = make([]string, 0)
+	for len(data) > 0 {
+		length := int(data[0])
+		if length == 0 || 1+length > len(data) {
+			return fmt.Errorf("alpn ID of %d bytes", length)
+		}
+		p.IDs = append(p.IDs, string(data[1:1+length]))
+		data = data[1+length:]
+	}
+	return nil
+}
+
+// SvcNoDefaultALPN says the service doesn't support the default protocol of
+// the scheme, so only the protocols listed in alpn may be used.
+type SvcNoDefaultALPN struct{}
+
+func (p *SvcNoDefaultALPN) Key() SvcParamKey { return SvcParamNoDefaultALPN }
+
+func (p *SvcNoDefaultALPN) String() string { return "" }
+
+func (p *SvcNoDefaultALPN) pack() ([]byte, error) { return nil, nil }
+
+func (p *SvcNoDefaultALPN) unpack(data []byte) error {
+	if len(data) != 0 {
+		return fmt.Errorf("no-default-alpn of %d bytes", len(data))
+	}
+	return nil
+}
+
+// SvcPort is the port the service listens on, if not the default one.
+type SvcPort struct {
+	Port uint16
+}
+
+func (p *SvcPort) Key() SvcParamKey { return SvcParamPort }
+
+func (p *SvcPort) String() string { return strconv.Itoa(int(p.Port)) }
+
+func (p *SvcPort) pack() ([]byte, error) {
+	return []byte{byte(p.Port >> 8), byte(p.Port)}, nil
+}
+
+func (p *SvcPort) unpack(data []byte) error {
+	if len(data) != 2 {
+		return fmt.Errorf("port of %d bytes", len(data))
+	}
+	p.Port = uint16(data[0])<<8 | uint16(data[1])
+	return nil
+}
+
+// SvcIPv4Hint holds IPv4 addresses of the target, which may be used before
+// its A records are known.
+type SvcIPv4Hint struct {
+	Addrs []net.IP
+}
+
+func (p *SvcIPv4Hint) Key() SvcParamKey { return SvcParamIPv4Hint }
+
+func (p *SvcIPv4Hint) String() string {
+	addrs := make([]string, 0, len(p.Addrs))
+	for _, addr := range p.Addrs {
+		addrs = append(addrs, addr.String())
+	}
+	return strings.Join(addrs, ",")
+}
+
+func (p *SvcIPv4Hint) pack() ([]byte, error) {
+	data := make([]byte, 0, net.IPv4len*len(p.Addrs))
+	for _, addr := range p.Addrs {
+		ip4 := addr.To4()
+		if ip4 == nil {
+			return nil, fmt.Errorf("ipv4hint %v is not an IPv4 address", addr)
+		}
+		data = append(data, ip4...)
+	}
+	return data, nil
+}
+
+func (p *SvcIPv4Hint) unpack(data []byte) error {
+	addrs, err := unpackAddrs(data, net.IPv4len)
+	if err != nil {
+		return fmt.Errorf("ipv4hint: %w", err)
+	}
+	p.Addrs = addrs
+	return nil
+}
+
+// SvcECH holds the Encrypted ClientHello configuration of the service, an
+// ECHConfigList which is passed on to TLS as it is.
+type SvcECH struct {
+	Config []byte
+}
+
+func (p *SvcECH) Key() SvcParamKey { return SvcParamECH }
+
+// String returns the configuration in base64, as per RFC 9460 section 9.
+func (p *SvcECH) String() string {
+	return base64.StdEncoding.EncodeToString(p.Config)
+}
+
+func (p *SvcECH) pack() ([]byte, error) { return p.Config, nil }
+
+func (p *SvcECH) unpack(data []byte) error {
+	p.Config = append([]byte(nil), data...)
+	return nil
+}
+
+// SvcIPv6Hint holds IPv6 addresses of the target, which may be used before
+// its AAAA records are known.
+type SvcIPv6Hint struct {
+	Addrs []net.IP
+}
+
+func (p *SvcIPv6Hint) Key() SvcParamKey { return SvcParamIPv6Hint }
+
+func (p *SvcIPv6Hint) String() string {
+	addrs := make([]string, 0, len(p.Addrs))
+	for _, addr := range p.Addrs {
+		// net.IP writes IPv4-mapped addresses in dotted form only, which
+		// would read as an IPv4 address.
+		if ip4 := addr.To4(); ip4 != nil {
+			addrs = append(addrs, "::ffff:"+ip4.String())
+			continue
+		}
+		addrs = append(addrs, addr.String())
+	}
+	return strings.Join(addrs, ",")
+}
+
+func (p *SvcIPv6Hint) pack() ([]byte, error) {
+	data := make([]byte, 0, net.IPv6len*len(p.Addrs))
+	for _, addr := range p.Addrs {
+		ip6 := addr.To16()
+		if ip6 == nil {
+			return nil, fmt.Errorf("ipv6hint %v is not an IP address", addr)
+		}
+		data = append(data, ip6...)
+	}
+	return data, nil
+}
+
+func (p *SvcIPv6Hint) unpack(data []byte) error {
+	addrs, err := unpackAddrs(data, net.IPv6len)
+	if err != nil {
+		return fmt.Errorf("ipv6hint: %w", err)
+	}
+	p.Addrs = addrs
+	return nil
+}
+
+// unpackAddrs splits data into one or more addresses of size bytes each.
+func unpackAddrs(data []byte, size int) ([]net.IP, error) {
+	if len(data) == 0 || len(data)%size != 0 {
+		return nil, fmt.Errorf("%d bytes of addresses", len(data))
+	}
+	addrs := make([]net.IP, 0, len(data)/size)
+	for i := 0; i < len(data); i += size {
+		addrs = append(addrs, append(net.IP(nil), data[i:i+size]...))
+	}
+	return addrs, nil
+}
+
+// SvcUnknown is a service parameter with a key we don't know, holding the
+// value as it is on the wire.
+type SvcUnknown struct {
+	ParamKey SvcParamKey
+	Value    []byte
+}
+
+func (p *SvcUnknown) Key() SvcParamKey { return p.ParamKey }
+
+// String returns the value as it is. It is escaped along with the values of
+// the other parameters when the record is written out, see quoteSvcParamValue.
+func (p *SvcUnknown) String() string { return string(p.Value) }
+
+func (p *SvcUnknown) pack() ([]byte, error) { return p.Value, nil }
+
+func (p *SvcUnknown) unpack(data []byte) error {
+	p.Value = append([]byte(nil), data...)
+	return nil
+}
+
+// newSvcParam creates an empty parameter of the type for key.
+func newSvcParam(key SvcParamKey) SvcParam {
+	switch key {
+	case SvcParamMandatory:
+		return &SvcMandatory{}
+	case SvcParamALPN:
+		return &SvcALPN{}
+	case SvcParamNoDefaultALPN:
+		return &SvcNoDefaultALPN{}
+	case SvcParamPort:
+		return &SvcPort{}
+	case SvcParamIPv4Hint:
+		return &SvcIPv4Hint{}
+	case SvcParamECH:
+		return &SvcECH{}
+	case SvcParamIPv6Hint:
+		return &SvcIPv6Hint{}
+	default:
+		return &SvcUnknown{ParamKey: key}
+	}
+}
+
+// SVCBRecord represents the SVCB DNS record (RFC 9460), telling clients where
+// and how to connect to a service. A record with priority 0 is in AliasMode
+// and only points to another name to look up; otherwise it is in ServiceMode
+// and describes an endpoint, with Params telling how to connect to it.
+type SVCBRecord struct {
+	Domain   string
+	Priority uint16
+	Target   string
+	Params   []SvcParam
+	TTL      uint32
+}
+
+// HTTPSRecord represents the HTTPS DNS record, an SVCB record for HTTPS
+// origins which browsers look up before connecting.
+type HTTPSRecord struct {
+	SVCBRecord
+}
+
+// IsAlias reports whether the record is in AliasMode.
+func (s *SVCBRecord) IsAlias() bool {
+	return s.Priority == 0
+}
+
+// TargetName returns the name the record points to. A target of "." stands
+// for the owner of the record in ServiceMode, while in AliasMode it says the
+// service is not available, in which case TargetName returns false.
+func (s *SVCBRecord) TargetName() (string, bool) {
+	if s.Target != "" {
+		return s.Target, true
+	}
+	if s.IsAlias() {
+		return "", false
+	}
+	return s.Domain, true
+}
+
+// Param returns the parameter of the record with key, or nil if it has none.
+func (s *SVCBRecord) Param(key SvcParamKey) SvcParam {
+	for _, param := range s.Params {
+		if param.Key() == key {
+			return param
+		}
+	}
+	return nil
+}
+
+// String returns the data of the record in presentation format, like
+// `1 svc.example.com. alpn=h2,h3 port=8443`.
+func (s *SVCBRecord) String() string {
+	var b strings.Builder
+	b.WriteString(strconv.Itoa(int(s.Priority)))
+	b.WriteByte(' ')
+	b.WriteString(s.Target)
+	b.WriteByte('.')
+	for _, param := range sortedSvcParams(s.Params) {
+		b.WriteByte(' ')
+		b.WriteString(param.Key().String())
+		if value := param.String(); value != "" {
+			b.WriteByte('=')
+			b.WriteString(quoteSvcParamValue(value))
+		}
+	}
+	return b.String()
+}
+
+// quoteSvcParamValue escapes value as a character-string, quoting it when it
+// holds anything but plain characters.
+func quoteSvcParamValue(value string) string {
+	escaped := escapeCharacterString(value)
+	if escaped == value && !strings.ContainsAny(value, " ;()") {
+		return value
+	}
+	return `"` + escaped + `"`
+}
+
+// Read reads SVCBRecord data from the buffer.
+func (s *SVCBRecord) Read(buffer *bytepacketbuffer.BytePacketBuffer) error {
+	// Domain Name
+	if err := buffer.ReadQName(&s.Domain); err != nil {
+		return err
+	}
+	// QueryType
+	if _, err := buffer.ReadU16(); err != nil {
+		return err
+	}
+
+	if _, err := buffer.ReadU16(); err != nil {
+		return err
+	}
+
+	if ttl, err := buffer.ReadU32(); err != nil {
+		return err
+	} else {
+		s.TTL = ttl
+	}
+
+	dataLength, err := buffer.ReadU16()
+	if err != nil {
+		return err
+	}
+
+	return s.readData(buffer, dataLength)
+}
+
+// readData reads the priority, target and parameters making up length bytes
+// of record data. Parameters must come in increasing order of their keys, as
+// per RFC 9460 section 2.2.
+func (s *SVCBRecord) readData(buffer *bytepacketbuffer.BytePacketBuffer, length uint16) error {
+	end := buffer.GetPos() + int(length)
+
+	priority, err := buffer.ReadU16()
+	if err != nil {
+		return err
+	}
+	s.Priority = priority
+
+	if err := buffer.ReadQName(&s.Target); err != nil {
+		return err
+	}
+
+	s.Params = make([]SvcParam, 0)
+	for buffer.GetPos() < end {
+		key, err := buffer.ReadU16()
+		if err != nil {
+			return err
+		}
+		if len(s.Params) > 0 && SvcParamKey(key) <= s.Params[len(s.Params)-1].Key() {
+			return fmt.Errorf("SvcParam key %v out of order", SvcParamKey(key))
+		}
+		valueLength, err := buffer.ReadU16()
+		if err != nil {
+			return err
+		}
+		if buffer.GetPos()+int(valueLength) > end {
+			return fmt.Errorf("SvcParam %v of %d bytes overruns record data", SvcParamKey(key), valueLength)
+		}
+		data, err := buffer.GetRange(buffer.GetPos(), int(valueLength))
+		if err != nil {
+			return err
+		}
+		buffer.Step(int(valueLength))
+
+		param := newSvcParam(SvcParamKey(key))
+		if err := param.unpack(data); err != nil {
+			return fmt.Errorf("malformed SvcParam: %w", err)
+		}
+		s.Params = append(s.Params, param)
+	}
+	if buffer.GetPos() != end {
+		return errors.New("SVCB target overruns record data")
+	}
+
+	return nil
+}
+
+// Write writes SVCBRecord data to the buffer.
+func (s *SVCBRecord) Write(buffer *bytepacketbuffer.BytePacketBuffer) (uint, error) {
+	return s.write(buffer, SVCB)
+}
+
+// Write writes HTTPSRecord data to the buffer.
+func (h *HTTPSRecord) Write(buffer *bytepacketbuffer.BytePacketBuffer) (uint, error) {
+	return h.write(buffer, HTTPS)
+}
+
+// write writes the record as a record of qtype, SVCB or HTTPS, which share
+// the same format. Parameters are written in increasing order of their keys.
+func (s *SVCBRecord) write(buffer *bytepacketbuffer.BytePacketBuffer, qtype QueryType) (uint, error) {
+	start_pos := buffer.GetPos()
+	if err := buffer.WriteQName(s.Domain); err != nil {
+		return 0, err
+	}
+	if err := buffer.WriteU16(qtype.QueryTypeToNum()); err != nil {
+		return 0, err
+	}
+	if err := buffer.WriteU16(1); err != nil {
+		return 0, err
+	} // class
+	if err := buffer.WriteU32(s.TTL); err != nil {
+		return 0, err
+	}
+
+	pos := buffer.GetPos()
+
+	if err := buffer.WriteU16(0); err != nil {
+		return 0, err
+	}
+
+	if err := buffer.WriteU16(s.Priority); err != nil {
+		return 0, err
+	}
+	// The target must not be compressed, as per RFC 9460 section 2.2.
+	if err := buffer.WriteQNameUncompressed(s.Target); err != nil {
+		return 0, err
+	}
+
+	params := sortedSvcParams(s.Params)
+	for i, param := range params {
+		if i > 0 && param.Key() == params[i-1].Key() {
+			return 0, fmt.Errorf("duplicate SvcParam %v", param.Key())
+		}
+		data, err := param.pack()
+		if err != nil {
+			return 0, err
+		}
+		if len(data) > 0xFFFF {
+			return 0, fmt.Errorf("SvcParam %v of %d bytes", param.Key(), len(data))
+		}
+
+		if err := buffer.WriteU16(uint16(param.Key())); err != nil {
+			return 0, err
+		}
+		if err := buffer.WriteU16(uint16(len(data))); err != nil {
+			return 0, err
+		}
+		for _, b := range data {
+			if err := buffer.WriteU8(b); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	size := uint16(buffer.GetPos() - (pos + 2))
+	if err := buffer.SetU16(pos, size); err != nil {
+		return 0, err
+	}
+
+	return uint(buffer.GetPos() - start_pos), nil
+}
+
+// sortedSvcParams returns a copy of params ordered by key.
+func sortedSvcParams(params []SvcParam) []SvcParam {
+	sorted := append([]SvcParam(nil), params...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Key() < sorted[j].Key()
+	})
+	return sorted
+}
+
+func (s *SVCBRecord) ExtractIPv4() net.IP {
+	return nil
+}
+
+func (s *SVCBRecord) GetDomain() string {
+	return s.Domain
+}
+
+func (s *SVCBRecord) GetTTL() uint32 {
+	return s.TTL
+}
+
+func (s *SVCBRecord) WithTTL(ttl uint32) DnsRecord {
+	record := *s
+	record.TTL = ttl
+	return &record
+}
+
+func (s *SVCBRecord) WithDomain(domain string) DnsRecord {
+	record := *s
+	record.Domain = domain
+	return &record
+}
+
+func (h *HTTPSRecord) WithTTL(ttl uint32) DnsRecord {
+	record := *h
+	record.TTL = ttl
+	return &record
+}
+
+func (h *HTTPSRecord) WithDomain(domain string) DnsRecord {
+	record := *h
+	record.Domain = domain
+	return &record
+}
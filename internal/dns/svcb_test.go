@@ -0,0 +1,165 @@
+package dns
+
+import (
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sadityakumar9211/go-res/pkg/bytepacketbuffer"
+)
+
+func TestSVCBRoundTrip(t *testing.T) {
+	https := &HTTPSRecord{SVCBRecord{
+		Domain:   "example.com",
+		Priority: 1,
+		TTL:      300,
+		Params: []SvcParam{
+			&SvcIPv6Hint{Addrs: []net.IP{net.ParseIP("2001:db8::1"), net.ParseIP("::ffff:198.51.100.100")}},
+			&SvcPort{Port: 8443},
+			&SvcALPN{IDs: []string{`f\oo,bar`, "h2"}},
+			&SvcMandatory{Keys: []SvcParamKey{SvcParamALPN, SvcParamPort}},
+			&SvcNoDefaultALPN{},
+			&SvcIPv4Hint{Addrs: []net.IP{net.ParseIP("192.0.2.1")}},
+			&SvcECH{Config: []byte{1, 2, 3, 250}},
+			&SvcUnknown{ParamKey: 667, Value: []byte("hello world")},
+		},
+	}}
+	alias := &SVCBRecord{Domain: "_8443._foo.api.example.com", Priority: 0, Target: "svc4.example.net", TTL: 7200}
+
+	packet := NewDnsPacket()
+	packet.Answers = append(packet.Answers, https, alias)
+	buffer := bytepacketbuffer.NewBytePacketBuffer()
+	if err := packet.Write(&buffer); err != nil {
+		t.Fatalf("writing packet: %v", err)
+	}
+	buffer.Seek(0)
+	read, err := FromBuffer(&buffer)
+	if err != nil {
+		t.Fatalf("reading packet: %v", err)
+	}
+	if len(read.Answers) != 2 {
+		t.Fatalf("read %d answers, want 2", len(read.Answers))
+	}
+
+	tests := []struct {
+		record DnsRecord
+		qtype  QueryType
+		want   string
+	}{
+		{read.Answers[0], HTTPS, `1 . mandatory=alpn,port alpn="f\\\\oo\\,bar,h2" no-default-alpn port=8443 ipv4hint=192.0.2.1 ech=AQID+g== ipv6hint=2001:db8::1,::ffff:198.51.100.100 key667="hello world"`},
+		{read.Answers[1], SVCB, "0 svc4.example.net."},
+	}
+	for _, test := range tests {
+		if RecordType(test.record) != test.qtype {
+			t.Errorf("record %v read as %v, want %v", test.record, RecordType(test.record), test.qtype)
+		}
+		s, ok := test.record.(interface{ String() string })
+		if !ok {
+			t.Errorf("record %v has no presentation format", test.record)
+			continue
+		}
+		if got := s.String(); got != test.want {
+			t.Errorf("String() = %s, want %s", got, test.want)
+		}
+	}
+
+	port, ok := read.Answers[0].(*HTTPSRecord).Param(SvcParamPort).(*SvcPort)
+	if !ok || port.Port != 8443 {
+		t.Errorf("port parameter = %v, want 8443", port)
+	}
+	ech := read.Answers[0].(*HTTPSRecord).Param(SvcParamECH).(*SvcECH)
+	if !reflect.DeepEqual(ech.Config, []byte{1, 2, 3, 250}) {
+		t.Errorf("ech parameter = %v", ech.Config)
+	}
+}
+
+func TestSVCBTargetName(t *testing.T) {
+	tests := []struct {
+		record SVCBRecord
+		want   string
+		ok     bool
+	}{
+		{SVCBRecord{Domain: "example.com", Priority: 1}, "example.com", true},
+		{SVCBRecord{Domain: "example.com", Priority: 1, Target: "svc.example.net"}, "svc.example.net", true},
+		{SVCBRecord{Domain: "example.com", Priority: 0, Target: "svc.example.net"}, "svc.example.net", true},
+		{SVCBRecord{Domain: "example.com", Priority: 0}, "", false},
+	}
+	for _, test := range tests {
+		got, ok := test.record.TargetName()
+		if got != test.want || ok != test.ok {
+			t.Errorf("TargetName() of %v = %q, %v, want %q, %v", test.record.String(), got, ok, test.want, test.ok)
+		}
+	}
+}
+
+// svcbData returns record data with priority 1, the root as target and params,
+// given as key, length and value bytes.
+func svcbData(params ...byte) []byte {
+	return append([]byte{0, 1, 0}, params...)
+}
+
+func TestSVCBReadMalformed(t *testing.T) {
+	tests := []struct {
+		desc   string
+		data   []byte
+		length int // record data length, len(data) when 0
+		err    string
+	}{
+		{"keys out of order", svcbData(0, 3, 0, 2, 0, 80, 0, 1, 0, 3, 2, 'h', '2'), 0, "out of order"},
+		{"duplicate keys", svcbData(0, 3, 0, 2, 0, 80, 0, 3, 0, 2, 0, 81), 0, "out of order"},
+		{"empty mandatory", svcbData(0, 0, 0, 0), 0, "mandatory"},
+		{"odd mandatory", svcbData(0, 0, 0, 3, 0, 1, 0), 0, "mandatory"},
+		{"mandatory out of order", svcbData(0, 0, 0, 4, 0, 3, 0, 1), 0, "mandatory keys out of order"},
+		{"empty alpn", svcbData(0, 1, 0, 0), 0, "alpn"},
+		{"alpn ID overrun", svcbData(0, 1, 0, 3, 5, 'h', '2'), 0, "alpn ID"},
+		{"empty alpn ID", svcbData(0, 1, 0, 1, 0), 0, "alpn ID"},
+		{"no-default-alpn with value", svcbData(0, 2, 0, 1, 0), 0, "no-default-alpn"},
+		{"short port", svcbData(0, 3, 0, 1, 80), 0, "port"},
+		{"long port", svcbData(0, 3, 0, 3, 0, 80, 0), 0, "port"},
+		{"short ipv4hint", svcbData(0, 4, 0, 3, 192, 0, 2), 0, "ipv4hint"},
+		{"empty ipv4hint", svcbData(0, 4, 0, 0), 0, "ipv4hint"},
+		{"short ipv6hint", svcbData(0, 6, 0, 4, 0x20, 0x01, 0x0d, 0xb8), 0, "ipv6hint"},
+		{"value overruns data", svcbData(0, 3, 0, 4, 0, 80), 0, "overruns"},
+		{"target overruns data", svcbData(), 2, "overruns"},
+	}
+
+	for _, test := range tests {
+		length := test.length
+		if length == 0 {
+			length = len(test.data)
+		}
+		buffer := bytepacketbuffer.FromBytes(test.data)
+		var record SVCBRecord
+		err := record.readData(&buffer, uint16(length))
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: got error %v, want one mentioning %q", test.desc, err, test.err)
+		}
+	}
+}
+
+func TestSVCBWriteDuplicateKeys(t *testing.T) {
+	record := &SVCBRecord{Domain: "example.com", Priority: 1, Params: []SvcParam{&SvcPort{Port: 443}, &SvcPort{Port: 8443}}}
+	buffer := bytepacketbuffer.NewBytePacketBuffer()
+	if _, err := record.Write(&buffer); err == nil {
+		t.Error("wrote a record with a duplicate key")
+	}
+}
+
+func TestSVCBUnknownKeyEscaping(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"plain", `1 . key667=plain`},
+		{`a"b\c`, `1 . key667="a\"b\\c"`},
+		{"a b\x01", `1 . key667="a b\001"`},
+	}
+
+	for _, test := range tests {
+		record := &SVCBRecord{Priority: 1, Params: []SvcParam{&SvcUnknown{ParamKey: 667, Value: []byte(test.value)}}}
+		if got := record.String(); got != test.want {
+			t.Errorf("String() with key667 %q = %s, want %s", test.value, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package dns
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"github.com/sadityakumar9211/go-res/pkg/bytepacketbuffer"
+)
+
+// ErrMessageTooLarge is returned by WriteTCPMessage when a message is larger
+// than its two byte length prefix can describe.
+var ErrMessageTooLarge = errors.New("message exceeds 65535 bytes")
+
+// ReadTCPMessage reads a single DNS message framed with the two byte length
+// prefix used over TCP (RFC 1035 section 4.2.2, RFC 7766) into buffer. The
+// buffer is sized to hold exactly that message.
+func ReadTCPMessage(r io.Reader, buffer *bytepacketbuffer.BytePacketBuffer) error {
+	var prefix [2]byte
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		return err
+	}
+
+	length := int(binary.BigEndian.Uint16(prefix[:]))
+	data := make([]byte, length)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return err
+	}
+	buffer.Buf = data
+	buffer.Seek(0)
+	return nil
+}
+
+// WriteTCPMessage writes the message held in buffer up to its current position
+// to w, prefixed with its two byte length.
+func WriteTCPMessage(w io.Writer, buffer *bytepacketbuffer.BytePacketBuffer) error {
+	length := buffer.GetPos()
+	if length > bytepacketbuffer.MaxSize {
+		return ErrMessageTooLarge
+	}
+	msg := make([]byte, 2+length)
+	binary.BigEndian.PutUint16(msg, uint16(length))
+	copy(msg[2:], buffer.Buf[:length])
+
+	_, err := w.Write(msg)
+	return err
+}
@@ -0,0 +1,217 @@
+package dns
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/sadityakumar9211/go-res/pkg/bytepacketbuffer"
+)
+
+// maxCharacterString is the longest a single character-string can be, as its
+// length is stored in a single byte.
+const maxCharacterString = 255
+
+// TXTRecord represents the TXT DNS record, holding one or more character
+// strings (RFC 1035 section 3.3.14). The strings are kept exactly as they are
+// on the wire, and may hold arbitrary bytes.
+type TXTRecord struct {
+	Domain  string
+	Strings []string
+	TTL     uint32
+}
+
+// NewTXTRecord creates a TXT record holding value, split into as many
+// character-strings as needed.
+func NewTXTRecord(domain string, value string, ttl uint32) *TXTRecord {
+	return &TXTRecord{Domain: domain, Strings: splitCharacterStrings(value), TTL: ttl}
+}
+
+// Text returns the strings of the record joined together, which is how SPF,
+// DKIM and DMARC records longer than a single string are read.
+func (t *TXTRecord) Text() string {
+	return strings.Join(t.Strings, "")
+}
+
+// String returns the data of the record in zone file format: every string in
+// double quotes, with quotes and backslashes escaped, and bytes which aren't
+// printable written as \DDD.
+func (t *TXTRecord) String() string {
+	quoted := make([]string, 0, len(t.Strings))
+	for _, s := range t.Strings {
+		quoted = append(quoted, `"`+escapeCharacterString(s)+`"`)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// escapeCharacterString escapes s for use in a zone file, without the
+// surrounding quotes.
+func escapeCharacterString(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch ch := s[i]; {
+		case ch == '"' || ch == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(ch)
+		case ch < ' ' || ch > '~':
+			fmt.Fprintf(&b, "\\%03d", ch)
+		default:
+			b.WriteByte(ch)
+		}
+	}
+	return b.String()
+}
+
+// Read reads TXTRecord data from the buffer.
+func (t *TXTRecord) Read(buffer *bytepacketbuffer.BytePacketBuffer) error {
+	// Domain Name
+	if err := buffer.ReadQName(&t.Domain); err != nil {
+		return err
+	}
+	// QueryType
+	if _, err := buffer.ReadU16(); err != nil {
+		return err
+	}
+
+	if _, err := buffer.ReadU16(); err != nil {
+		return err
+	}
+
+	if ttl, err := buffer.ReadU32(); err != nil {
+		return err
+	} else {
+		t.TTL = ttl
+	}
+
+	dataLength, err := buffer.ReadU16()
+	if err != nil {
+		return err
+	}
+
+	values, err := readCharacterStrings(buffer, dataLength)
+	if err != nil {
+		return err
+	}
+	t.Strings = values
+
+	return nil
+}
+
+// readCharacterStrings reads the character-strings making up length bytes of
+// record data.
+func readCharacterStrings(buffer *bytepacketbuffer.BytePacketBuffer, length uint16) ([]string, error) {
+	values := make([]string, 0)
+	end := buffer.GetPos() + int(length)
+
+	for buffer.GetPos() < end {
+		stringLength, err := buffer.Read()
+		if err != nil {
+			return nil, err
+		}
+		if buffer.GetPos()+int(stringLength) > end {
+			return nil, fmt.Errorf("character-string of %d bytes overruns record data", stringLength)
+		}
+		data, err := buffer.GetRange(buffer.GetPos(), int(stringLength))
+		if err != nil {
+			return nil, err
+		}
+		buffer.Step(int(stringLength))
+
+		values = append(values, string(data))
+	}
+
+	return values, nil
+}
+
+// Write writes TXTRecord data to the buffer. Strings longer than 255 bytes are
+// split into several character-strings.
+func (t *TXTRecord) Write(buffer *bytepacketbuffer.BytePacketBuffer) (uint, error) {
+	start_pos := buffer.GetPos()
+	if err := buffer.WriteQName(t.Domain); err != nil {
+		return 0, err
+	}
+	if err := buffer.WriteU16(TXT.QueryTypeToNum()); err != nil {
+		return 0, err
+	}
+	if err := buffer.WriteU16(1); err != nil {
+		return 0, err
+	} // class
+	if err := buffer.WriteU32(t.TTL); err != nil {
+		return 0, err
+	}
+
+	pos := buffer.GetPos()
+
+	if err := buffer.WriteU16(0); err != nil {
+		return 0, err
+	}
+
+	// A TXT record holds at least one string, if only an empty one.
+	values := t.Strings
+	if len(values) == 0 {
+		values = []string{""}
+	}
+	for _, s := range values {
+		for _, chunk := range splitCharacterStrings(s) {
+			if err := writeCharacterString(buffer, chunk); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	size := uint16(buffer.GetPos() - (pos + 2))
+	if err := buffer.SetU16(pos, size); err != nil {
+		return 0, err
+	}
+
+	return uint(buffer.GetPos() - start_pos), nil
+}
+
+// writeCharacterString writes s, which is at most 255 bytes long, as a single
+// character-string.
+func writeCharacterString(buffer *bytepacketbuffer.BytePacketBuffer, s string) error {
+	if err := buffer.WriteU8(uint8(len(s))); err != nil {
+		return err
+	}
+	for i := 0; i < len(s); i++ {
+		if err := buffer.WriteU8(s[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitCharacterStrings splits value into chunks fitting into a single
+// character-string each. An empty value is a single empty string.
+func splitCharacterStrings(value string) []string {
+	chunks := make([]string, 0, len(value)/maxCharacterString+1)
+	for len(value) > maxCharacterString {
+		chunks = append(chunks, value[:maxCharacterString])
+		value = value[maxCharacterString:]
+	}
+	return append(chunks, value)
+}
+
+func (t *TXTRecord) ExtractIPv4() net.IP {
+	return nil
+}
+
+func (t *TXTRecord) GetDomain() string {
+	return t.Domain
+}
+
+func (t *TXTRecord) GetTTL() uint32 {
+	return t.TTL
+}
+
+func (t *TXTRecord) WithTTL(ttl uint32) DnsRecord {
+	record := *t
+	record.TTL = ttl
+	return &record
+}
+
+func (t *TXTRecord) WithDomain(domain string) DnsRecord {
+	record := *t
+	record.Domain = domain
+	return &record
+}
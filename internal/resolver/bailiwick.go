@@ -0,0 +1,36 @@
+package resolver
+
+import (
+	"fmt"
+
+	"github.com/sadityakumar9211/go-res/internal/dns"
+)
+
+// stripOutOfBailiwick drops every record of response the server which sent it
+// has no authority over. A server we reached while resolving names in zone
+// only gets to speak for zone and the names below it: anything else it sends,
+// be it answers, name servers or glue addresses, could be an attempt to poison
+// the cache and is discarded before it is looked at.
+func stripOutOfBailiwick(response *dns.DnsPacket, zone string) {
+	response.Answers = inBailiwick(response.Answers, zone)
+	response.Authorities = inBailiwick(response.Authorities, zone)
+	response.Resources = inBailiwick(response.Resources, zone)
+}
+
+// inBailiwick returns the records of records owned by zone or a name below it.
+func inBailiwick(records []dns.DnsRecord, zone string) []dns.DnsRecord {
+	kept := make([]dns.DnsRecord, 0, len(records))
+	for _, record := range records {
+		// The OPT record is no data about any name, it belongs to the message.
+		if _, ok := record.(*dns.OPTRecord); ok {
+			kept = append(kept, record)
+			continue
+		}
+		if !dns.IsSubdomain(record.GetDomain(), zone) {
+			fmt.Printf("Discarding out of bailiwick record for %q from a server for %q\n", record.GetDomain(), zone)
+			continue
+		}
+		kept = append(kept, record)
+	}
+	return kept
+}
@@ -0,0 +1,133 @@
+package resolver
+
+import (
+	"net"
+	"testing"
+
+	"github.com/sadityakumar9211/go-res/internal/dns"
+)
+
+func TestStripOutOfBailiwick(t *testing.T) {
+	response := dns.NewDnsPacket()
+	response.Answers = []dns.DnsRecord{
+		&dns.ARecord{Domain: "www.example.com", Addr: net.ParseIP("192.0.2.1"), TTL: 300},
+		&dns.ARecord{Domain: "www.badexample.com", Addr: net.ParseIP("192.0.2.66"), TTL: 300},
+	}
+	response.Authorities = []dns.DnsRecord{
+		&dns.NSRecord{Domain: "example.com", Host: "ns1.example.com", TTL: 300},
+		&dns.NSRecord{Domain: "com", Host: "ns.evil.org", TTL: 300},
+	}
+	response.Resources = []dns.DnsRecord{
+		&dns.ARecord{Domain: "ns1.example.com", Addr: net.ParseIP("192.0.2.53"), TTL: 300},
+		&dns.ARecord{Domain: "ns.evil.org", Addr: net.ParseIP("192.0.2.66"), TTL: 300},
+		dns.NewOPTRecord(1232),
+	}
+
+	stripOutOfBailiwick(response, "example.com")
+
+	tests := []struct {
+		section string
+		records []dns.DnsRecord
+		want    []string
+	}{
+		{"answer", response.Answers, []string{"www.example.com"}},
+		{"authority", response.Authorities, []string{"example.com"}},
+		{"additional", response.Resources, []string{"ns1.example.com", ""}},
+	}
+	for _, test := range tests {
+		if len(test.records) != len(test.want) {
+			t.Errorf("%s section holds %d records, want %d", test.section, len(test.records), len(test.want))
+			continue
+		}
+		for i, record := range test.records {
+			if record.GetDomain() != test.want[i] {
+				t.Errorf("%s record %d is for %q, want %q", test.section, i, record.GetDomain(), test.want[i])
+			}
+		}
+	}
+	if response.GetOPT() == nil {
+		t.Error("OPT record was dropped")
+	}
+}
+
+func TestDelegationFrom(t *testing.T) {
+	tests := []struct {
+		desc      string
+		qname     string
+		zone      string
+		ns        []*dns.NSRecord
+		glue      []dns.DnsRecord
+		wantZone  string
+		wantAddrs int
+	}{
+		{
+			desc:  "referral with glue",
+			qname: "www.example.com",
+			zone:  "com",
+			ns:    []*dns.NSRecord{{Domain: "example.com", Host: "ns1.example.com", TTL: 300}},
+			glue: []dns.DnsRecord{
+				&dns.ARecord{Domain: "ns1.example.com", Addr: net.ParseIP("192.0.2.53"), TTL: 300},
+				&dns.AAAARecord{Domain: "ns1.example.com", Addr: net.ParseIP("2001:db8::53"), TTL: 300},
+			},
+			wantZone:  "example.com",
+			wantAddrs: 2,
+		},
+		{
+			desc:      "glue for a name server out of bailiwick",
+			qname:     "www.example.com",
+			zone:      "com",
+			ns:        []*dns.NSRecord{{Domain: "example.com", Host: "ns.evil.org", TTL: 300}},
+			glue:      []dns.DnsRecord{&dns.ARecord{Domain: "ns.evil.org", Addr: net.ParseIP("192.0.2.66"), TTL: 300}},
+			wantZone:  "example.com",
+			wantAddrs: 0,
+		},
+		{
+			desc:  "name server records owned outside of the zone",
+			qname: "www.example.com",
+			zone:  "com",
+			ns:    []*dns.NSRecord{{Domain: "example.org", Host: "ns1.example.org", TTL: 300}},
+		},
+		{
+			desc:  "upward referral",
+			qname: "www.example.com",
+			zone:  "example.com",
+			ns:    []*dns.NSRecord{{Domain: "com", Host: "a.gtld-servers.net", TTL: 300}},
+		},
+		{
+			desc:  "referral to the zone itself",
+			qname: "www.example.com",
+			zone:  "example.com",
+			ns:    []*dns.NSRecord{{Domain: "example.com", Host: "ns1.example.com", TTL: 300}},
+		},
+		{
+			desc:  "sideways referral",
+			qname: "www.example.com",
+			zone:  "example.com",
+			ns:    []*dns.NSRecord{{Domain: "other.example.com", Host: "ns1.other.example.com", TTL: 300}},
+		},
+		{
+			desc:  "referral to a label sharing a suffix",
+			qname: "www.badexample.com",
+			zone:  "com",
+			ns:    []*dns.NSRecord{{Domain: "example.com", Host: "ns1.example.com", TTL: 300}},
+		},
+	}
+
+	for _, test := range tests {
+		response := dns.NewDnsPacket()
+		for _, ns := range test.ns {
+			response.Authorities = append(response.Authorities, ns)
+		}
+		response.Resources = test.glue
+		stripOutOfBailiwick(response, test.zone)
+
+		delegation, _ := delegationFrom(test.qname, test.zone, response)
+		if delegation.Zone != test.wantZone {
+			t.Errorf("%s: delegated to %q, want %q", test.desc, delegation.Zone, test.wantZone)
+			continue
+		}
+		if got := len(delegation.Addrs()); got != test.wantAddrs {
+			t.Errorf("%s: %d glue addresses, want %d", test.desc, got, test.wantAddrs)
+		}
+	}
+}
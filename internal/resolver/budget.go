@@ -0,0 +1,70 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrBudgetExceeded is returned when answering a question takes more work than
+// the resolver is configured to spend on it. Errors wrapping it tell which
+// limit was hit.
+var ErrBudgetExceeded = errors.New("resolution budget exceeded")
+
+// budget keeps track of the work done to answer a single question, including
+// the lookups of name server names and alias targets it leads to. The lookups
+// made for a question run one at a time, so a budget needs no locking.
+type budget struct {
+	config    *Config
+	start     time.Time
+	queries   int // queries sent to other name servers so far
+	referrals int // referrals followed so far
+	depth     int // nesting of name server name lookups
+}
+
+func newBudget(config *Config) *budget {
+	return &budget{config: config, start: time.Now()}
+}
+
+// spendQuery accounts for a query about to be sent to another name server.
+func (b *budget) spendQuery() error {
+	b.queries++
+	if b.queries > b.config.MaxQueries {
+		return fmt.Errorf("%w: sent %d queries to other name servers", ErrBudgetExceeded, b.config.MaxQueries)
+	}
+	return nil
+}
+
+// spendReferral accounts for a referral about to be followed.
+func (b *budget) spendReferral() error {
+	b.referrals++
+	if b.referrals > b.config.MaxReferrals {
+		return fmt.Errorf("%w: followed %d referrals", ErrBudgetExceeded, b.config.MaxReferrals)
+	}
+	return nil
+}
+
+// enter accounts for starting the lookup of a name server name, which has to
+// be ended by calling leave.
+func (b *budget) enter() error {
+	if b.depth >= b.config.MaxDepth {
+		return fmt.Errorf("%w: name server lookups nested %d deep", ErrBudgetExceeded, b.depth)
+	}
+	b.depth++
+	return nil
+}
+
+func (b *budget) leave() {
+	b.depth--
+}
+
+// checkTime returns an error once the question can no longer be answered in
+// time.
+func (b *budget) checkTime(ctx context.Context) error {
+	err := ctx.Err()
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("%w: gave up after %v", ErrBudgetExceeded, time.Since(b.start).Round(time.Millisecond))
+	}
+	return err
+}
@@ -0,0 +1,88 @@
+package resolver
+
+import (
+	"fmt"
+
+	"github.com/sadityakumar9211/go-res/internal/dns"
+)
+
+// maxCNAMEChain is the number of aliases we follow for a single question
+// before giving up on it.
+const maxCNAMEChain = 12
+
+// maxNameLength is the longest a name may be in presentation format.
+const maxNameLength = 253
+
+// followChain walks the alias chain starting at name through records, and
+// returns the records making up the chain along with the data found at its
+// end. CNAME records synthesized from DNAME records are part of the chain. The
+// returned target is the name the chain ends at when no data for it is among
+// records, and has to be looked up on its own; it is empty when the chain is
+// complete. Every name visited is added to seen, and an error is returned once
+// the chain loops or grows beyond maxCNAMEChain.
+func followChain(name string, qtype dns.QueryType, records []dns.DnsRecord, seen map[string]bool) ([]dns.DnsRecord, string, error) {
+	chain := make([]dns.DnsRecord, 0)
+	aliased := false
+
+	for {
+		// Records for the name itself end the chain.
+		found := false
+		for _, record := range records {
+			if dns.EqualNames(record.GetDomain(), name) && dns.RecordType(record) == qtype {
+				chain = append(chain, record)
+				found = true
+			}
+		}
+		if found || qtype == dns.CNAME {
+			return chain, "", nil
+		}
+
+		next, alias := nextAlias(name, qtype, records)
+		if alias == nil {
+			if aliased {
+				return chain, name, nil
+			}
+			return chain, "", nil
+		}
+		chain = append(chain, alias...)
+		aliased = true
+
+		key := dns.CanonicalName(next)
+		if seen[key] {
+			return nil, "", fmt.Errorf("CNAME loop at %q", next)
+		}
+		if len(seen) >= maxCNAMEChain {
+			return nil, "", fmt.Errorf("CNAME chain for %q longer than %d", name, maxCNAMEChain)
+		}
+		seen[key] = true
+		name = next
+	}
+}
+
+// nextAlias looks for the record redirecting name elsewhere. A DNAME record
+// for a zone above name takes precedence over a CNAME record, which servers
+// send along for the benefit of old resolvers: we synthesize the CNAME record
+// ourselves as per RFC 6672 section 3.2, rather than trusting the one sent.
+func nextAlias(name string, qtype dns.QueryType, records []dns.DnsRecord) (string, []dns.DnsRecord) {
+	if qtype != dns.DNAME {
+		for _, record := range records {
+			dname, ok := record.(*dns.DNAMERecord)
+			if !ok {
+				continue
+			}
+			target, ok := dname.Substitute(name)
+			if !ok || len(target) > maxNameLength {
+				continue
+			}
+			cname := &dns.CNAMERecord{Domain: name, Host: target, TTL: dname.TTL}
+			return target, []dns.DnsRecord{dname, cname}
+		}
+	}
+
+	for _, record := range records {
+		if cname, ok := record.(*dns.CNAMERecord); ok && dns.EqualNames(cname.Domain, name) {
+			return cname.Host, []dns.DnsRecord{cname}
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,109 @@
+package resolver
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/sadityakumar9211/go-res/internal/cache"
+	"github.com/sadityakumar9211/go-res/internal/dns"
+)
+
+// Stats counts the work done by the resolver since it was created.
+type Stats struct {
+	Resolutions          uint64 // questions resolved through Resolve
+	CoalescedResolutions uint64 // of which shared the resolution of an identical question in progress
+	Lookups              uint64 // lookups sent to other name servers
+	CoalescedLookups     uint64 // of which shared an identical lookup in progress
+}
+
+// stats holds the counters behind Stats.
+type stats struct {
+	resolutions          atomic.Uint64
+	coalescedResolutions atomic.Uint64
+	lookups              atomic.Uint64
+	coalescedLookups     atomic.Uint64
+}
+
+// Stats returns the counters of the work done by the resolver.
+func (r *Resolver) Stats() Stats {
+	return Stats{
+		Resolutions:          r.stats.resolutions.Load(),
+		CoalescedResolutions: r.stats.coalescedResolutions.Load(),
+		Lookups:              r.stats.lookups.Load(),
+		CoalescedLookups:     r.stats.coalescedLookups.Load(),
+	}
+}
+
+// flightKey identifies a lookup in progress: a question, and the name server
+// it was sent to. The server is empty for questions being resolved.
+type flightKey struct {
+	server string
+	key    cache.Key
+}
+
+// flight is a lookup in progress, which callers asking the same can wait for.
+type flight struct {
+	done     chan struct{}
+	response *dns.DnsPacket
+	err      error
+}
+
+// flightGroup deduplicates identical lookups in progress, so that when many
+// clients ask for the same name at once, only one of them does the work. It
+// is safe for concurrent use.
+type flightGroup struct {
+	mu      sync.Mutex
+	flights map[flightKey]*flight
+}
+
+func newFlightGroup() *flightGroup {
+	return &flightGroup{flights: make(map[flightKey]*flight)}
+}
+
+// do calls fn in a goroutine of its own, unless a call for key is already in
+// progress, and waits for whichever call it is to finish, or for ctx to be
+// done. It reports whether the result was shared with an earlier caller.
+// Every caller gets its own copy of the response. The call carries on when
+// callers give up on it, so that the others still get their answer; fn picks
+// the context it runs in accordingly.
+func (g *flightGroup) do(ctx context.Context, key flightKey, fn func() (*dns.DnsPacket, error)) (*dns.DnsPacket, bool, error) {
+	g.mu.Lock()
+	f, shared := g.flights[key]
+	if !shared {
+		f = &flight{done: make(chan struct{})}
+		g.flights[key] = f
+		go func() {
+			f.response, f.err = fn()
+
+			g.mu.Lock()
+			delete(g.flights, key)
+			g.mu.Unlock()
+			close(f.done)
+		}()
+	}
+	g.mu.Unlock()
+
+	select {
+	case <-f.done:
+		if f.err != nil {
+			return nil, shared, f.err
+		}
+		return copyPacket(f.response), shared, nil
+	case <-ctx.Done():
+		return nil, shared, ctx.Err()
+	}
+}
+
+// copyPacket returns a copy of packet, whose header and sections can be
+// changed without affecting packet. The records themselves are shared.
+func copyPacket(packet *dns.DnsPacket) *dns.DnsPacket {
+	header := *packet.Header
+	return &dns.DnsPacket{
+		Header:      &header,
+		Questions:   append([]*dns.DnsQuestion(nil), packet.Questions...),
+		Answers:     append([]dns.DnsRecord(nil), packet.Answers...),
+		Authorities: append([]dns.DnsRecord(nil), packet.Authorities...),
+		Resources:   append([]dns.DnsRecord(nil), packet.Resources...),
+	}
+}
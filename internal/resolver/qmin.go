@@ -0,0 +1,81 @@
+package resolver
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sadityakumar9211/go-res/internal/dns"
+	"github.com/sadityakumar9211/go-res/pkg/bytepacketbuffer"
+)
+
+// maxMinimisedQueries bounds the number of minimised queries sent for a single
+// name, as names with many labels would otherwise take as many queries. Once
+// reached, the full name is sent.
+const maxMinimisedQueries = 10
+
+// qnameMinimiser implements QNAME minimisation (RFC 9156): rather than telling
+// every server on the way down the tree the full name we're after, we only ask
+// each one for the name one label below the longest one we know to exist, with
+// an A query. That is enough for the server to refer us to the zone below,
+// while the rest of the name stays private.
+//
+// In relaxed mode, as RFC 9156 section 3 allows, a minimised query which fails
+// or is answered in any other way than with a referral or NOERROR makes us
+// give up on minimisation for the rest of the lookup: some servers answer
+// NXDOMAIN for names without records (empty non-terminals) or can't deal with
+// such queries at all.
+type qnameMinimiser struct {
+	qname   string
+	enabled bool
+	queries int // minimised queries sent so far
+}
+
+func newQNameMinimiser(qname string, enabled bool) *qnameMinimiser {
+	return &qnameMinimiser{qname: qname, enabled: enabled}
+}
+
+// next returns the question to ask the servers of the next zone, given that
+// exists is the longest name above the query name known to exist.
+func (q *qnameMinimiser) next(exists string, qtype dns.QueryType) (string, dns.QueryType) {
+	if !q.enabled || q.queries >= maxMinimisedQueries {
+		return q.qname, qtype
+	}
+
+	name := childName(q.qname, exists)
+	if name == q.qname {
+		return q.qname, qtype
+	}
+	q.queries++
+	return name, dns.A
+}
+
+// relax turns minimisation off after the minimised query for name failed.
+func (q *qnameMinimiser) relax(name string, err error) {
+	fmt.Printf("Minimised query for %v failed, asking for %v instead: %v\n", name, q.qname, err)
+	q.enabled = false
+}
+
+// childName returns the name one label below parent on the way to qname, or
+// qname itself when it is directly below parent.
+func childName(qname string, parent string) string {
+	labels := bytepacketbuffer.SplitDNSName(qname)
+	depth := 1
+	if parent != "" {
+		depth += len(bytepacketbuffer.SplitDNSName(parent))
+	}
+	if depth >= len(labels) {
+		return qname
+	}
+	return strings.Join(labels[len(labels)-depth:], ".")
+}
+
+// hasAliases reports whether the answer section of response holds any CNAME
+// or DNAME records.
+func hasAliases(response *dns.DnsPacket) bool {
+	for _, record := range response.Answers {
+		if qtype := dns.RecordType(record); qtype == dns.CNAME || qtype == dns.DNAME {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,554 @@
+package resolver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/sadityakumar9211/go-res/internal/cache"
+	"github.com/sadityakumar9211/go-res/internal/dns"
+	buf "github.com/sadityakumar9211/go-res/pkg/bytepacketbuffer"
+)
+
+// delegationCacheSize is the number of zone cuts remembered by the resolver.
+const delegationCacheSize = 10000
+
+// ednsBufferSize is the UDP payload size we advertise to other name servers.
+// 1232 bytes avoids IP fragmentation on virtually every path.
+const ednsBufferSize = 1232
+
+// Address families used to reach other name servers, see Config.IPMode.
+const (
+	DualStack = "dual"
+	IPv4Only  = "ipv4"
+	IPv6Only  = "ipv6"
+)
+
+// Config holds the tunables of the resolver.
+type Config struct {
+	Network        string        // transport used to reach other name servers, "udp" or "tcp"
+	IPMode         string        // address families used to reach other name servers, DualStack, IPv4Only or IPv6Only
+	CacheSize      int           // memory used by the answer cache, in bytes
+	MaxNegativeTTL time.Duration // upper bound on caching NXDOMAIN and NODATA answers
+
+	// CaseRandomisation randomises the case of the letters of query names
+	// sent to other name servers, and only accepts responses echoing the name
+	// exactly. This makes forged responses harder to get accepted, as the
+	// attacker has to guess the case of every letter on top of the query ID
+	// and port (0x20 encoding). Name servers which don't preserve the case of
+	// the question are treated as failing.
+	CaseRandomisation bool
+
+	// DisableQNameMinimisation sends the full query name to every name server
+	// on the way down the tree, instead of only the labels it needs to know
+	// about (RFC 9156).
+	DisableQNameMinimisation bool
+
+	// Limits on the work done to answer a single question. A question which
+	// can't be answered within them fails with ErrBudgetExceeded.
+	MaxReferrals int           // referrals followed
+	MaxDepth     int           // nesting of lookups of name server names
+	MaxQueries   int           // queries sent to other name servers
+	MaxTime      time.Duration // time spent on the question
+
+	// RootHints are the root name servers to start from, until priming tells
+	// us otherwise. The built-in hints are used when empty.
+	RootHints []cache.NameServer
+}
+
+// DefaultConfig returns the configuration used when nothing else is specified.
+func DefaultConfig() Config {
+	return Config{
+		Network:        "udp",
+		IPMode:         DualStack,
+		CacheSize:      32 << 20,
+		MaxNegativeTTL: time.Hour,
+		MaxReferrals:   16,
+		MaxDepth:       4,
+		MaxQueries:     64,
+		MaxTime:        10 * time.Second,
+	}
+}
+
+// Resolver resolves queries by walking the DNS tree from the root down to the
+// authoritative name servers. Answers are cached, so repeated lookups of the
+// same name are served without going upstream. It is safe for concurrent use.
+type Resolver struct {
+	config      Config
+	cache       *cache.Cache
+	delegations *cache.Delegations
+	infra       *cache.Infra
+	flights     *flightGroup
+	stats       stats
+
+	rootsMu sync.RWMutex
+	roots   []cache.NameServer
+}
+
+// New creates a new Resolver.
+func New(config Config) *Resolver {
+	defaults := DefaultConfig()
+	if config.Network != "tcp" {
+		config.Network = defaults.Network
+	}
+	if config.IPMode != IPv4Only && config.IPMode != IPv6Only {
+		config.IPMode = defaults.IPMode
+	}
+	if config.CacheSize <= 0 {
+		config.CacheSize = defaults.CacheSize
+	}
+	if config.MaxNegativeTTL <= 0 {
+		config.MaxNegativeTTL = defaults.MaxNegativeTTL
+	}
+	if config.MaxReferrals <= 0 {
+		config.MaxReferrals = defaults.MaxReferrals
+	}
+	if config.MaxDepth <= 0 {
+		config.MaxDepth = defaults.MaxDepth
+	}
+	if config.MaxQueries <= 0 {
+		config.MaxQueries = defaults.MaxQueries
+	}
+	if config.MaxTime <= 0 {
+		config.MaxTime = defaults.MaxTime
+	}
+	if len(config.RootHints) == 0 {
+		config.RootHints = rootHints
+	}
+
+	return &Resolver{
+		config:      config,
+		cache:       cache.New(config.CacheSize),
+		delegations: cache.NewDelegations(delegationCacheSize),
+		infra:       cache.NewInfra(infraCacheSize),
+		flights:     newFlightGroup(),
+		roots:       config.RootHints,
+	}
+}
+
+// Resolve answers the question for qname and qtype, from the cache if
+// possible and by a recursive lookup otherwise. When qname turns out to be an
+// alias, the chain of CNAME and DNAME records is followed across zones, and
+// the answer holds the whole chain followed by the records at its end. The
+// answer to an SVCB or HTTPS question comes with the records of its targets in
+// the additional section.
+//
+// Clients asking the same question while it is being resolved share the
+// resolution, rather than each sending queries of their own.
+func (r *Resolver) Resolve(ctx context.Context, qname string, qtype dns.QueryType) (*dns.DnsPacket, error) {
+	r.stats.resolutions.Add(1)
+
+	key := flightKey{key: cache.NewKey(qname, qtype, dns.ClassIN)}
+	response, shared, err := r.flights.do(ctx, key, func() (*dns.DnsPacket, error) {
+		// The resolution is not bound to the client which started it, as
+		// others may be waiting for it as well.
+		ctx, cancel := context.WithTimeout(context.Background(), r.config.MaxTime)
+		defer cancel()
+
+		b := newBudget(&r.config)
+		response, err := r.resolve(ctx, b, qname, qtype)
+		if err != nil {
+			return nil, err
+		}
+		r.addServiceTargets(ctx, b, response, qtype)
+		return response, nil
+	})
+	if shared {
+		r.stats.coalescedResolutions.Add(1)
+		fmt.Printf("Joined resolution of %v %v in progress\n", qtype, qname)
+	}
+	return response, err
+}
+
+// resolve answers the question for qname and qtype like Resolve, spending the
+// work it takes from b.
+func (r *Resolver) resolve(ctx context.Context, b *budget, qname string, qtype dns.QueryType) (*dns.DnsPacket, error) {
+	response := newResponse(qname, qtype)
+	seen := map[string]bool{dns.CanonicalName(qname): true}
+
+	name := qname
+	for {
+		step, err := r.resolveName(ctx, b, name, qtype)
+		if err != nil {
+			return nil, err
+		}
+
+		chain, target, err := followChain(name, qtype, step.Answers, seen)
+		if err != nil {
+			return nil, err
+		}
+		response.Header.ResultCode = step.Header.ResultCode
+		response.Answers = append(response.Answers, chain...)
+		response.Authorities = step.Authorities
+		response.Resources = step.Resources
+
+		// The chain leaves the zone of the server which answered, so the rest
+		// of it has to be looked up from the servers of the target.
+		if target == "" || step.Header.ResultCode != dns.NOERROR {
+			return response, nil
+		}
+		fmt.Printf("Following alias chain of %v to %v\n", qname, target)
+		name = target
+	}
+}
+
+// resolveName answers the question for qname and qtype without following
+// aliases. Answers are cached as they come.
+func (r *Resolver) resolveName(ctx context.Context, b *budget, qname string, qtype dns.QueryType) (*dns.DnsPacket, error) {
+	key := cache.NewKey(qname, qtype, dns.ClassIN)
+	if records, ok := r.cache.Get(key); ok {
+		fmt.Printf("Cache hit for %v %v\n", qtype, qname)
+		response := newResponse(qname, qtype)
+		response.Answers = records
+		return response, nil
+	}
+	if rcode, soa, ok := r.cache.GetNegative(key); ok {
+		fmt.Printf("Negative cache hit for %v %v\n", qtype, qname)
+		response := newResponse(qname, qtype)
+		response.Header.ResultCode = rcode
+		response.Authorities = soa
+		return response, nil
+	}
+
+	response, err := r.recursiveLookup(ctx, b, qname, qtype)
+	if err != nil {
+		return nil, err
+	}
+
+	// An NXDOMAIN answer may still hold the aliases leading to the name which
+	// doesn't exist. Those are cached, the negative answer goes with the
+	// name at the end of the chain.
+	switch rcode := response.Header.ResultCode; {
+	case (rcode == dns.NOERROR || rcode == dns.NXDOMAIN) && len(response.Answers) > 0:
+		r.cache.Set(key, response.Answers)
+		if rcode == dns.NXDOMAIN {
+			r.cacheChainEnd(qname, qtype, response)
+		}
+	case rcode == dns.NXDOMAIN || rcode == dns.NOERROR:
+		r.cacheNegative(key, response)
+	}
+	return response, nil
+}
+
+// cacheChainEnd caches the NXDOMAIN response for the name at the end of the
+// alias chain held in its answers, which is the name that doesn't exist.
+func (r *Resolver) cacheChainEnd(qname string, qtype dns.QueryType, response *dns.DnsPacket) {
+	_, target, err := followChain(qname, qtype, response.Answers, map[string]bool{dns.CanonicalName(qname): true})
+	if err != nil || target == "" {
+		return
+	}
+	r.cacheNegative(cache.NewKey(target, qtype, dns.ClassIN), response)
+}
+
+// cacheNegative caches an NXDOMAIN or NODATA response as per RFC 2308. Such a
+// response is only cached when it carries the SOA record of the zone, whose
+// minimum field bounds how long the answer may be cached.
+func (r *Resolver) cacheNegative(key cache.Key, response *dns.DnsPacket) {
+	soa := response.GetSOA()
+	if soa == nil {
+		return
+	}
+
+	ttl := soa.NegativeTTL()
+	if maxTTL := uint32(r.config.MaxNegativeTTL / time.Second); maxTTL < ttl {
+		ttl = maxTTL
+	}
+	r.cache.SetNegative(key, response.Header.ResultCode, soa, ttl)
+}
+
+// newResponse creates a response to a question for qname and qtype.
+func newResponse(qname string, qtype dns.QueryType) *dns.DnsPacket {
+	response := dns.NewDnsPacket()
+	response.Header.Response = true
+	response.Questions = append(response.Questions, &dns.DnsQuestion{Name: qname, QType: qtype})
+	return response
+}
+
+// lookup asks server about qname. When the same is already being asked of the
+// same server, the response to that query is shared instead of sending
+// another one, and lookup reports that it only joined the query.
+func (r *Resolver) lookup(ctx context.Context, b *budget, qname string, qtype dns.QueryType, server string) (*dns.DnsPacket, bool, error) {
+	if err := b.spendQuery(); err != nil {
+		return nil, false, err
+	}
+	r.stats.lookups.Add(1)
+
+	key := flightKey{server: server, key: cache.NewKey(qname, qtype, dns.ClassIN)}
+	response, shared, err := r.flights.do(ctx, key, func() (*dns.DnsPacket, error) {
+		// The query is shared by everyone asking the same meanwhile, so it
+		// is not bound by the deadline or budget of the caller which sent it.
+		ctx, cancel := context.WithTimeout(context.Background(), exchangeTimeout)
+		defer cancel()
+
+		return r.forward(ctx, newBudget(&r.config), qname, qtype, server)
+	})
+	if shared {
+		r.stats.coalescedLookups.Add(1)
+		fmt.Printf("Joined lookup of %v %v at %v in progress\n", qtype, qname, server)
+	}
+	return response, shared, err
+}
+
+func (r *Resolver) forward(ctx context.Context, b *budget, qname string, qtype dns.QueryType, server string) (*dns.DnsPacket, error) {
+	// Forward queries to the specified DNS server
+
+	resPacket, err := r.query(ctx, b, qname, qtype, server, true)
+	if err != nil {
+		return nil, err
+	}
+
+	// Servers which don't implement EDNS answer FORMERR or NOTIMP without an
+	// OPT record of their own. Those we ask again with a plain DNS query.
+	rcode := resPacket.Header.ResultCode
+	if (rcode == dns.FORMERR || rcode == dns.NOTIMP) && resPacket.GetOPT() == nil {
+		fmt.Printf("%v does not support EDNS, retrying without it\n", server)
+		resPacket, err = r.query(ctx, b, qname, qtype, server, false)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	jsonData, err := json.MarshalIndent(resPacket, "  ", "   ")
+	if err != nil {
+		fmt.Println("Error marshling to JSON: ", err)
+		return resPacket, nil
+	}
+	fmt.Println(string(jsonData))
+	return resPacket, nil
+}
+
+// query sends a single query for qname to server and parses the response.
+// With edns set, the query carries an OPT record advertising ednsBufferSize.
+func (r *Resolver) query(ctx context.Context, b *budget, qname string, qtype dns.QueryType, server string, edns bool) (*dns.DnsPacket, error) {
+	if err := b.spendQuery(); err != nil {
+		return nil, err
+	}
+
+	id, err := randomUint16()
+	if err != nil {
+		return nil, err
+	}
+
+	name := qname
+	if r.config.CaseRandomisation {
+		if name, err = randomiseCase(qname); err != nil {
+			return nil, err
+		}
+	}
+
+	packet := dns.NewDnsPacket()
+	packet.Header.ID = id
+	packet.Header.Questions = 1
+	packet.Header.RecursionDesired = true
+	packet.Questions = append(packet.Questions, &dns.DnsQuestion{Name: name, QType: qtype})
+	if edns {
+		packet.Resources = append(packet.Resources, dns.NewOPTRecord(ednsBufferSize))
+	}
+
+	reqBuffer := buf.NewBytePacketBuffer()
+	if err := packet.Write(&reqBuffer); err != nil {
+		return nil, err
+	}
+
+	resBuffer, err := exchange(ctx, r.config.Network, server, packet, &reqBuffer, r.config.CaseRandomisation)
+	if err != nil {
+		return nil, err
+	}
+
+	// A response with the TC bit set only holds whatever fitted into a UDP
+	// datagram, so we ask the same server again over TCP to get all of it.
+	if r.config.Network == "udp" && isTruncated(resBuffer) {
+		fmt.Printf("Truncated response from %v, retrying over TCP\n", server)
+		if err := b.spendQuery(); err != nil {
+			return nil, err
+		}
+		resBuffer, err = exchange(ctx, "tcp", server, packet, &reqBuffer, r.config.CaseRandomisation)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	response, err := dns.FromBuffer(resBuffer)
+	if err != nil {
+		return nil, err
+	}
+	if name != qname {
+		restoreCase(response, qname)
+	}
+	return response, nil
+}
+
+func (r *Resolver) recursiveLookup(ctx context.Context, b *budget, qname string, qtype dns.QueryType) (*dns.DnsPacket, error) {
+	// We start at the closest zone cut we already know of, or at the root.
+	cut := r.rootCut()
+	if delegation, ok := r.delegations.Closest(qname); ok {
+		cut = delegation
+		fmt.Printf("Starting at cached delegation for %q\n", delegation.Zone)
+	}
+	// cutTTL is how long a zone cut learned from a referral may be cached,
+	// zero for those which didn't come from one.
+	var cutTTL uint32
+
+	// Unless disabled, servers are only told as much of qname as they need to
+	// refer us further down the tree, see minimise. exists is the longest
+	// name above qname we know to exist.
+	qmin := newQNameMinimiser(qname, !r.config.DisableQNameMinimisation)
+	exists := cut.Zone
+
+	// Since it might take an arbitrary number of steps, we enter a loop which
+	// only stops early when the question runs out of budget.
+	for {
+		if err := b.checkTime(ctx); err != nil {
+			return nil, err
+		}
+
+		name, nameType := qmin.next(exists, qtype)
+		minimised := name != qname
+
+		// Every server of the zone is tried until one of them gives us
+		// something to work with. The zone is all it is trusted to tell us
+		// about, see stripOutOfBailiwick.
+		response, err := r.queryZone(ctx, b, cut, name, nameType)
+		if err != nil && minimised && !errors.Is(err, ErrBudgetExceeded) {
+			qmin.relax(name, err)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// Referrals are remembered once they have been of use, so that the
+		// next lookup of a name in the same zone can skip the servers above
+		// it. That includes addresses of name servers which had to be
+		// resolved.
+		r.delegations.Set(cut, cutTTL)
+
+		delegation, ttl := delegationFrom(name, cut.Zone, response)
+		if minimised && len(delegation.Servers) == 0 {
+			// No zone cut at name: it is in the same zone as its parent, and
+			// we move on to the next label. Any other answer is taken as a
+			// sign of a server which can't cope with minimised queries.
+			if response.Header.ResultCode == dns.NOERROR && !hasAliases(response) {
+				exists = name
+			} else {
+				qmin.relax(name, fmt.Errorf("answered %v", response.Header.ResultCode))
+			}
+			continue
+		}
+
+		if !minimised && len(response.Answers) > 0 && response.Header.ResultCode == dns.NOERROR {
+			return response, nil
+		} else if !minimised && response.Header.ResultCode == dns.NXDOMAIN {
+			return response, nil
+		}
+
+		// Otherwise, we'll follow the referral to the name servers of a zone
+		// further down the tree. If there is none, we'll go with what the last
+		// server told us. That's also how an answer without any records for
+		// the name (NODATA) ends up being returned.
+		if len(delegation.Servers) == 0 {
+			return response, nil
+		}
+		if err := b.spendReferral(); err != nil {
+			return nil, err
+		}
+		cut, cutTTL = delegation, ttl
+		exists = cut.Zone
+	}
+}
+
+// nameServerPort is the port other name servers are reached on.
+var nameServerPort = "53"
+
+// serverAddr returns the address to reach the name server at ip on.
+func serverAddr(ip net.IP) string {
+	return net.JoinHostPort(ip.String(), nameServerPort)
+}
+
+// usable reports whether name servers at addr can be reached in the
+// configured IP mode.
+func (r *Resolver) usable(addr net.IP) bool {
+	switch r.config.IPMode {
+	case IPv4Only:
+		return addr.To4() != nil
+	case IPv6Only:
+		return addr.To4() == nil
+	default:
+		return true
+	}
+}
+
+// addrTypes returns the types of the address records of name servers which
+// can be reached in the configured IP mode.
+func (r *Resolver) addrTypes() []dns.QueryType {
+	switch r.config.IPMode {
+	case IPv4Only:
+		return []dns.QueryType{dns.A}
+	case IPv6Only:
+		return []dns.QueryType{dns.AAAA}
+	default:
+		return []dns.QueryType{dns.A, dns.AAAA}
+	}
+}
+
+// delegationFrom collects the zone cut a referral for qname points to: the
+// name servers of the closest enclosing zone listed in the authority section,
+// and the glue addresses given for them in the additional section. Only a
+// zone below zone, the one the responding server is authoritative for, is a
+// referral: a server can't hand out authority it doesn't have. It also returns
+// how long the delegation may be cached, which is the smallest TTL of the
+// records involved.
+func delegationFrom(qname string, zone string, response *dns.DnsPacket) (*cache.Delegation, uint32) {
+	var delegation *cache.Delegation
+	var ttl uint32
+
+	for _, record := range response.Authorities {
+		nsRecord, ok := record.(*dns.NSRecord)
+		if !ok || !dns.IsSubdomain(qname, nsRecord.Domain) {
+			continue
+		}
+		if !dns.IsSubdomain(nsRecord.Domain, zone) || dns.EqualNames(nsRecord.Domain, zone) {
+			continue
+		}
+		if delegation == nil {
+			delegation = &cache.Delegation{Zone: nsRecord.Domain}
+			ttl = nsRecord.TTL
+		}
+		if !dns.EqualNames(nsRecord.Domain, delegation.Zone) {
+			continue
+		}
+		if nsRecord.TTL < ttl {
+			ttl = nsRecord.TTL
+		}
+
+		// Glue is only taken from the additional section for name servers
+		// within the zone of the responding server, see stripOutOfBailiwick.
+		server := cache.NameServer{Host: nsRecord.Host}
+		for _, record := range response.Resources {
+			if !dns.EqualNames(record.GetDomain(), nsRecord.Host) {
+				continue
+			}
+			switch glue := record.(type) {
+			case *dns.ARecord:
+				server.Addrs = append(server.Addrs, glue.Addr)
+			case *dns.AAAARecord:
+				server.Addrs = append(server.Addrs, glue.Addr)
+			default:
+				continue
+			}
+			if record.GetTTL() < ttl {
+				ttl = record.GetTTL()
+			}
+		}
+		delegation.Servers = append(delegation.Servers, server)
+	}
+
+	if delegation == nil {
+		return &cache.Delegation{}, 0
+	}
+	return delegation, ttl
+}
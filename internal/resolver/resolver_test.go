@@ -0,0 +1,166 @@
+package resolver
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/sadityakumar9211/go-res/internal/cache"
+	"github.com/sadityakumar9211/go-res/internal/dns"
+	buf "github.com/sadityakumar9211/go-res/pkg/bytepacketbuffer"
+)
+
+// fakeServer is a name server on the loopback address which is authoritative
+// for the whole tree and answers queries using its handler. It remembers the
+// questions it was asked.
+type fakeServer struct {
+	handler func(question *dns.DnsQuestion, response *dns.DnsPacket)
+
+	mu    sync.Mutex
+	asked []dns.DnsQuestion
+}
+
+// newTestResolver starts server and returns a resolver using it as its only
+// root server.
+func newTestResolver(t *testing.T, server *fakeServer, config Config) *Resolver {
+	t.Helper()
+
+	socket, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { socket.Close() })
+	go server.serve(socket)
+
+	port := nameServerPort
+	_, nameServerPort, _ = net.SplitHostPort(socket.LocalAddr().String())
+	t.Cleanup(func() { nameServerPort = port })
+
+	config.IPMode = IPv4Only
+	config.DisableQNameMinimisation = true
+	config.RootHints = []cache.NameServer{{Host: "a.root.test", Addrs: []net.IP{net.IPv4(127, 0, 0, 1)}}}
+	return New(config)
+}
+
+func (s *fakeServer) serve(socket *net.UDPConn) {
+	for {
+		data := make([]byte, ednsBufferSize)
+		n, src, err := socket.ReadFromUDP(data)
+		if err != nil {
+			return
+		}
+
+		reqBuffer := buf.FromBytes(data[:n])
+		request, err := dns.FromBuffer(&reqBuffer)
+		if err != nil || len(request.Questions) != 1 {
+			continue
+		}
+		question := request.Questions[0]
+		s.mu.Lock()
+		s.asked = append(s.asked, *question)
+		s.mu.Unlock()
+
+		response := dns.NewDnsPacket()
+		response.Header.ID = request.Header.ID
+		response.Header.Response = true
+		response.Header.AuthoritativeAnswer = true
+		response.Questions = append(response.Questions, question)
+		if question.Name == "" && question.QType == dns.NS {
+			response.Answers = append(response.Answers, &dns.NSRecord{Domain: "", Host: "a.root.test", TTL: 3600})
+			response.Resources = append(response.Resources, &dns.ARecord{Domain: "a.root.test", Addr: net.IPv4(127, 0, 0, 1), TTL: 3600})
+		} else {
+			s.handler(question, response)
+		}
+
+		resBuffer := buf.NewBytePacketBufferSize(ednsBufferSize)
+		if err := response.Write(&resBuffer); err != nil {
+			continue
+		}
+		socket.WriteToUDP(resBuffer.Buf[:resBuffer.GetPos()], src)
+	}
+}
+
+// queries returns how many times the server was asked for name and qtype.
+func (s *fakeServer) queries(name string, qtype dns.QueryType) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	count := 0
+	for _, question := range s.asked {
+		if dns.EqualNames(question.Name, name) && question.QType == qtype {
+			count++
+		}
+	}
+	return count
+}
+
+func TestCaseRandomisationRestoresCase(t *testing.T) {
+	server := &fakeServer{handler: func(question *dns.DnsQuestion, response *dns.DnsPacket) {
+		// Answer with the owner name exactly as asked.
+		response.Answers = append(response.Answers, &dns.ARecord{Domain: question.Name, Addr: net.IPv4(192, 0, 2, 1), TTL: 300})
+	}}
+	resolver := newTestResolver(t, server, Config{CaseRandomisation: true})
+
+	// The second lookup is served from the cache.
+	for i := 0; i < 2; i++ {
+		response, err := resolver.Resolve(context.Background(), "www.example.test", dns.A)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := response.Questions[0].Name; got != "www.example.test" {
+			t.Errorf("lookup %d: question name %q, want %q", i, got, "www.example.test")
+		}
+		if len(response.Answers) != 1 {
+			t.Fatalf("lookup %d: got %d answers, want 1", i, len(response.Answers))
+		}
+		if got := response.Answers[0].GetDomain(); got != "www.example.test" {
+			t.Errorf("lookup %d: answer owner name %q, want %q", i, got, "www.example.test")
+		}
+	}
+	if got := server.queries("www.example.test", dns.A); got != 1 {
+		t.Errorf("server asked %d times, want 1", got)
+	}
+}
+
+func TestNXDOMAINCachedForEndOfChain(t *testing.T) {
+	server := &fakeServer{handler: func(question *dns.DnsQuestion, response *dns.DnsPacket) {
+		if dns.EqualNames(question.Name, "alias.example.test") {
+			response.Answers = append(response.Answers, &dns.CNAMERecord{Domain: question.Name, Host: "missing.example.test", TTL: 300})
+		}
+		response.Header.ResultCode = dns.NXDOMAIN
+		response.Authorities = append(response.Authorities, &dns.SOARecord{
+			Domain: "example.test", MName: "ns.example.test", RName: "hostmaster.example.test",
+			Serial: 1, Minimum: 60, TTL: 300,
+		})
+	}}
+	resolver := newTestResolver(t, server, Config{})
+
+	response, err := resolver.Resolve(context.Background(), "alias.example.test", dns.A)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Header.ResultCode != dns.NXDOMAIN {
+		t.Fatalf("got %v for the alias, want NXDOMAIN", response.Header.ResultCode)
+	}
+
+	// The target is known not to exist from the answer for the alias.
+	for i := 0; i < 2; i++ {
+		response, err := resolver.Resolve(context.Background(), "missing.example.test", dns.A)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if response.Header.ResultCode != dns.NXDOMAIN {
+			t.Errorf("lookup %d: got %v for the target, want NXDOMAIN", i, response.Header.ResultCode)
+		}
+		if response.GetSOA() == nil {
+			t.Errorf("lookup %d: no SOA record in the answer for the target", i)
+		}
+	}
+	if got := server.queries("missing.example.test", dns.A); got != 0 {
+		t.Errorf("server asked %d times for the target, want 0", got)
+	}
+	if got := server.queries("alias.example.test", dns.A); got != 1 {
+		t.Errorf("server asked %d times for the alias, want 1", got)
+	}
+}
@@ -0,0 +1,216 @@
+package resolver
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/sadityakumar9211/go-res/internal/cache"
+	"github.com/sadityakumar9211/go-res/internal/dns"
+)
+
+// rootHints are the 13 root name servers as published by IANA in named.root.
+var rootHints = []cache.NameServer{
+	{Host: "a.root-servers.net", Addrs: parseIPs("198.41.0.4", "2001:503:ba3e::2:30")},
+	{Host: "b.root-servers.net", Addrs: parseIPs("199.9.14.201", "2001:500:200::b")},
+	{Host: "c.root-servers.net", Addrs: parseIPs("192.33.4.12", "2001:500:2::c")},
+	{Host: "d.root-servers.net", Addrs: parseIPs("199.7.91.13", "2001:500:2d::d")},
+	{Host: "e.root-servers.net", Addrs: parseIPs("192.203.230.10", "2001:500:a8::e")},
+	{Host: "f.root-servers.net", Addrs: parseIPs("192.5.5.241", "2001:500:2f::f")},
+	{Host: "g.root-servers.net", Addrs: parseIPs("192.112.36.4", "2001:500:12::d0d")},
+	{Host: "h.root-servers.net", Addrs: parseIPs("198.97.190.53", "2001:500:1::53")},
+	{Host: "i.root-servers.net", Addrs: parseIPs("192.36.148.17", "2001:7fe::53")},
+	{Host: "j.root-servers.net", Addrs: parseIPs("192.58.128.30", "2001:503:c27::2:30")},
+	{Host: "k.root-servers.net", Addrs: parseIPs("193.0.14.129", "2001:7fd::1")},
+	{Host: "l.root-servers.net", Addrs: parseIPs("199.7.83.42", "2001:500:9f::42")},
+	{Host: "m.root-servers.net", Addrs: parseIPs("202.12.27.33", "2001:dc3::35")},
+}
+
+func parseIPs(addrs ...string) []net.IP {
+	ips := make([]net.IP, 0, len(addrs))
+	for _, addr := range addrs {
+		ips = append(ips, net.ParseIP(addr))
+	}
+	return ips
+}
+
+// LoadRootHintsFile reads root hints from the file at path. See LoadRootHints.
+func LoadRootHintsFile(path string) ([]cache.NameServer, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return LoadRootHints(file)
+}
+
+// LoadRootHints reads root hints in zone file format, like the named.root
+// file published by IANA: NS records for the root, and A and AAAA records for
+// the name servers they point to. Other records are ignored.
+func LoadRootHints(r io.Reader) ([]cache.NameServer, error) {
+	servers := make([]cache.NameServer, 0)
+	addrs := make(map[string][]net.IP)
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if i := strings.IndexByte(text, ';'); i >= 0 {
+			text = text[:i]
+		}
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+
+		// Records are written as "name [ttl] [class] type data", where the TTL
+		// and class are optional.
+		name := canonicalName(fields[0])
+		fields = fields[1:]
+		for len(fields) > 2 && (isNumber(fields[0]) || strings.EqualFold(fields[0], "IN")) {
+			fields = fields[1:]
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("root hints line %d: malformed record", line)
+		}
+
+		switch strings.ToUpper(fields[0]) {
+		case "NS":
+			if name == "" {
+				servers = append(servers, cache.NameServer{Host: canonicalName(fields[1])})
+			}
+		case "A", "AAAA":
+			addr := net.ParseIP(fields[1])
+			if addr == nil {
+				return nil, fmt.Errorf("root hints line %d: invalid address %q", line, fields[1])
+			}
+			addrs[name] = append(addrs[name], addr)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	usable := make([]cache.NameServer, 0, len(servers))
+	for _, server := range servers {
+		server.Addrs = addrs[server.Host]
+		if len(server.Addrs) > 0 {
+			usable = append(usable, server)
+		}
+	}
+	if len(usable) == 0 {
+		return nil, errors.New("root hints: no root name server with an address")
+	}
+	return usable, nil
+}
+
+// canonicalName lowercases name and strips the trailing dot of a fully
+// qualified name, which is how names are represented everywhere else.
+func canonicalName(name string) string {
+	return strings.TrimSuffix(dns.CanonicalName(name), ".")
+}
+
+func isNumber(s string) bool {
+	for _, ch := range s {
+		if ch < '0' || ch > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// Prime asks one of the root servers from the hints for the current list of
+// root servers and their addresses, and uses that list from then on (RFC 8109).
+// Root servers are tried in random order until one of them answers.
+func (r *Resolver) Prime(ctx context.Context) error {
+	b := newBudget(&r.config)
+	var lastErr error
+	for _, addr := range r.rootAddrs() {
+		if err := b.checkTime(ctx); err != nil {
+			return err
+		}
+
+		fmt.Printf("Priming root servers using %v\n", addr)
+		response, _, err := r.lookup(ctx, b, "", dns.NS, serverAddr(addr))
+		if errors.Is(err, ErrBudgetExceeded) {
+			return err
+		}
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		servers := make([]cache.NameServer, 0)
+		for _, record := range response.Answers {
+			nsRecord, ok := record.(*dns.NSRecord)
+			if !ok || nsRecord.Domain != "" {
+				continue
+			}
+			server := cache.NameServer{Host: nsRecord.Host}
+			for _, record := range response.Resources {
+				if aRecord, ok := record.(*dns.ARecord); ok && dns.EqualNames(aRecord.Domain, nsRecord.Host) {
+					server.Addrs = append(server.Addrs, aRecord.Addr)
+				}
+				if aaaaRecord, ok := record.(*dns.AAAARecord); ok && dns.EqualNames(aaaaRecord.Domain, nsRecord.Host) {
+					server.Addrs = append(server.Addrs, aaaaRecord.Addr)
+				}
+			}
+			if len(server.Addrs) > 0 {
+				servers = append(servers, server)
+			}
+		}
+
+		if len(servers) == 0 {
+			lastErr = fmt.Errorf("priming response from %v lists no root server addresses", addr)
+			continue
+		}
+
+		r.rootsMu.Lock()
+		r.roots = servers
+		r.rootsMu.Unlock()
+		fmt.Printf("Primed %d root servers\n", len(servers))
+		return nil
+	}
+
+	if lastErr == nil {
+		lastErr = errors.New("no root server to prime from")
+	}
+	return lastErr
+}
+
+// rootAddrs returns the addresses of the root servers in random order, so
+// that the load is spread and an unreachable root is only hit now and then.
+func (r *Resolver) rootAddrs() []net.IP {
+	r.rootsMu.RLock()
+	defer r.rootsMu.RUnlock()
+
+	addrs := make([]net.IP, 0)
+	for _, server := range r.roots {
+		for _, addr := range server.Addrs {
+			if r.usable(addr) {
+				addrs = append(addrs, addr)
+			}
+		}
+	}
+	rand.Shuffle(len(addrs), func(i, j int) { addrs[i], addrs[j] = addrs[j], addrs[i] })
+	return addrs
+}
+
+// rootCut returns the zone cut at the root, with the root servers we know of.
+func (r *Resolver) rootCut() *cache.Delegation {
+	r.rootsMu.RLock()
+	defer r.rootsMu.RUnlock()
+
+	cut := &cache.Delegation{Zone: ""}
+	for _, server := range r.roots {
+		server.Addrs = append([]net.IP(nil), server.Addrs...)
+		cut.Servers = append(cut.Servers, server)
+	}
+	return cut
+}
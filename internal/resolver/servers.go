@@ -0,0 +1,274 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/sadityakumar9211/go-res/internal/cache"
+	"github.com/sadityakumar9211/go-res/internal/dns"
+)
+
+// infraCacheSize is the number of name servers the resolver keeps track of.
+const infraCacheSize = 10000
+
+// lameDuration is how long a name server which failed us is avoided.
+const lameDuration = 15 * time.Minute
+
+// explorationRate is the fraction of queries sent to a name server other than
+// the fastest one, see orderAddrs.
+const explorationRate = 0.05
+
+// Every name server of a zone cut is tried in turn, in up to maxRounds rounds.
+// The time given to a single server starts at attemptTimeout and doubles with
+// every round, and rounds after the first are preceded by a pause which starts
+// at retryDelay and doubles as well.
+const (
+	maxRounds      = 3
+	attemptTimeout = time.Second
+	retryDelay     = 50 * time.Millisecond
+)
+
+// queryZone asks the name servers of cut about qname, one after the other,
+// until one of them gives a usable response: an answer, a referral further
+// down the tree or an authoritative negative answer. Servers which fail are
+// marked as lame by attempt, and are only tried after all others on later
+// queries.
+// Name servers for which no address is known yet are resolved once the known
+// ones have failed, and the addresses found are added to cut.
+func (r *Resolver) queryZone(ctx context.Context, b *budget, cut *cache.Delegation, qname string, qtype dns.QueryType) (*dns.DnsPacket, error) {
+	resolved := make(map[string]bool)
+	var lastErr error
+
+	for round := 0; round < maxRounds; round++ {
+		if round > 0 {
+			if err := sleep(ctx, retryDelay<<(round-1)); err != nil {
+				return nil, b.checkTime(ctx)
+			}
+		}
+		timeout := attemptTimeout << round
+		if timeout > exchangeTimeout {
+			timeout = exchangeTimeout
+		}
+
+		pending := r.orderAddrs(cut.Zone, cut.Addrs())
+		for {
+			if err := b.checkTime(ctx); err != nil {
+				return nil, err
+			}
+
+			if len(pending) == 0 {
+				host, ok := r.unresolvedHost(cut, resolved)
+				if !ok {
+					break
+				}
+				resolved[host] = true
+
+				addrs, err := r.resolveHost(ctx, b, host)
+				if errors.Is(err, ErrBudgetExceeded) {
+					return nil, err
+				}
+				if err != nil {
+					lastErr = err
+					continue
+				}
+				for _, addr := range addrs {
+					cut.AddAddr(host, addr)
+				}
+				pending = r.orderAddrs(cut.Zone, addrs)
+				continue
+			}
+
+			addr := pending[0]
+			pending = pending[1:]
+
+			response, err := r.attempt(ctx, b, cut.Zone, addr, timeout, qname, qtype)
+			if errors.Is(err, ErrBudgetExceeded) {
+				return nil, err
+			}
+			if err == nil {
+				return response, nil
+			}
+			if ctx.Err() != nil {
+				return nil, b.checkTime(ctx)
+			}
+			lastErr = err
+		}
+	}
+
+	if lastErr == nil {
+		lastErr = errors.New("no name server address")
+	}
+	return nil, fmt.Errorf("no name server for %q answered: %w", cut.Zone, lastErr)
+}
+
+// attempt sends the query for qname to the name server at addr, which serves
+// zone, and checks whether the response is of any use. How the server did is
+// recorded, and a server which fails is marked as lame for zone; not so when
+// the query was only joined, as its timing and outcome belong to the caller
+// which sent it.
+func (r *Resolver) attempt(ctx context.Context, b *budget, zone string, addr net.IP, timeout time.Duration, qname string, qtype dns.QueryType) (*dns.DnsPacket, error) {
+	fmt.Printf("\nAttempting lookup of %v %v with NS %v\n", qtype, qname, addr)
+
+	attemptCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	response, shared, err := r.lookup(attemptCtx, b, qname, qtype, serverAddr(addr))
+	if errors.Is(err, ErrBudgetExceeded) {
+		return nil, err
+	}
+	if err == nil {
+		if !shared {
+			r.infra.RecordRTT(addr, time.Since(start))
+		}
+		response, err = usableResponse(zone, addr, qname, response)
+	} else if !shared {
+		r.infra.RecordFailure(addr, timeout)
+	}
+
+	// Running out of time for the whole question is not the fault of the
+	// server.
+	if err != nil && !shared && ctx.Err() == nil {
+		fmt.Printf("Marking %v lame for %q: %v\n", addr, zone, err)
+		r.infra.MarkLame(addr, zone, lameDuration)
+	}
+	return response, err
+}
+
+// usableResponse checks whether the response of the name server at addr,
+// which serves zone, is of any use, after dropping the records it has no
+// authority over.
+func usableResponse(zone string, addr net.IP, qname string, response *dns.DnsPacket) (*dns.DnsPacket, error) {
+	stripOutOfBailiwick(response, zone)
+
+	switch rcode := response.Header.ResultCode; {
+	case rcode == dns.NXDOMAIN:
+		return response, nil
+	case rcode != dns.NOERROR:
+		return nil, fmt.Errorf("%v answered %v", addr, rcode)
+	case len(response.Answers) > 0 || response.Header.AuthoritativeAnswer:
+		return response, nil
+	}
+
+	// A server which neither answers nor refers us further down the tree is
+	// not authoritative for the zone it was delegated (a lame delegation).
+	if delegation, _ := delegationFrom(qname, zone, response); len(delegation.Servers) == 0 {
+		return nil, fmt.Errorf("%v is not authoritative for %q", addr, zone)
+	}
+	return response, nil
+}
+
+// resolveHost looks up the addresses of the name server host, of the families
+// allowed by the IP mode.
+func (r *Resolver) resolveHost(ctx context.Context, b *budget, host string) ([]net.IP, error) {
+	if err := b.enter(); err != nil {
+		return nil, err
+	}
+	defer b.leave()
+
+	addrs := make([]net.IP, 0)
+	var lastErr error
+	for _, qtype := range r.addrTypes() {
+		response, err := r.resolve(ctx, b, host, qtype)
+		if errors.Is(err, ErrBudgetExceeded) {
+			return nil, err
+		}
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		for _, record := range response.Answers {
+			switch record := record.(type) {
+			case *dns.ARecord:
+				addrs = append(addrs, record.Addr)
+			case *dns.AAAARecord:
+				addrs = append(addrs, record.Addr)
+			}
+		}
+	}
+
+	if len(addrs) == 0 {
+		if lastErr != nil {
+			return nil, lastErr
+		}
+		return nil, fmt.Errorf("name server %q has no address", host)
+	}
+	return addrs, nil
+}
+
+// unresolvedHost returns a name server of cut without any known address we
+// can use, which isn't in resolved yet.
+func (r *Resolver) unresolvedHost(cut *cache.Delegation, resolved map[string]bool) (string, bool) {
+	for _, server := range cut.Servers {
+		host := dns.CanonicalName(server.Host)
+		if resolved[host] {
+			continue
+		}
+		usable := false
+		for _, addr := range server.Addrs {
+			usable = usable || r.usable(addr)
+		}
+		if !usable {
+			return host, true
+		}
+	}
+	return "", false
+}
+
+// orderAddrs returns the addresses of the name servers of zone in the order
+// they should be tried: fastest first, as per their smoothed round trip time,
+// and unhealthy servers last. A server is unhealthy when it is known to be lame
+// for zone, or failed to respond the last time we asked it anything. Servers
+// we know nothing about yet go first, so that every server gets measured. Now
+// and then a slower server is tried first, so that its estimate stays current:
+// servers which were slow once get a chance to show they got faster.
+func (r *Resolver) orderAddrs(zone string, addrs []net.IP) []net.IP {
+	healthy := make([]net.IP, 0, len(addrs))
+	unhealthy := make([]net.IP, 0)
+	srtts := make(map[string]time.Duration, len(addrs))
+	for _, addr := range addrs {
+		if !r.usable(addr) {
+			continue
+		}
+		srtt, failures, _ := r.infra.SRTT(addr)
+		srtts[addr.String()] = srtt
+		if failures > 0 || r.infra.IsLame(addr, zone) {
+			unhealthy = append(unhealthy, addr)
+		} else {
+			healthy = append(healthy, addr)
+		}
+	}
+
+	// Shuffling first spreads the load among servers which are equally fast,
+	// or which we know nothing about.
+	rand.Shuffle(len(healthy), func(i, j int) { healthy[i], healthy[j] = healthy[j], healthy[i] })
+	rand.Shuffle(len(unhealthy), func(i, j int) { unhealthy[i], unhealthy[j] = unhealthy[j], unhealthy[i] })
+	sort.SliceStable(healthy, func(i, j int) bool {
+		return srtts[healthy[i].String()] < srtts[healthy[j].String()]
+	})
+
+	if len(healthy) > 1 && rand.Float64() < explorationRate {
+		i := 1 + rand.Intn(len(healthy)-1)
+		healthy[0], healthy[i] = healthy[i], healthy[0]
+	}
+	return append(healthy, unhealthy...)
+}
+
+// sleep waits for d, or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,91 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/sadityakumar9211/go-res/internal/dns"
+)
+
+// addServiceTargets saves clients of an SVCB or HTTPS question the lookups
+// that usually follow, as RFC 9460 section 4.2 suggests: AliasMode records are
+// followed to the records of their target, and the addresses of the targets of
+// ServiceMode records are looked up. All of these go into the additional
+// section of response. They are only a help, so a failed lookup is skipped.
+func (r *Resolver) addServiceTargets(ctx context.Context, b *budget, response *dns.DnsPacket, qtype dns.QueryType) {
+	if (qtype != dns.SVCB && qtype != dns.HTTPS) || response.Header.ResultCode != dns.NOERROR {
+		return
+	}
+
+	// Aliases and endpoints are tracked apart, as the target of an alias is
+	// often the owner of ServiceMode records with the target ".".
+	aliases := make(map[string]bool)
+	endpoints := make(map[string]bool)
+	records := serviceBindings(response.Answers, qtype)
+	for len(records) > 0 && len(aliases) < maxCNAMEChain {
+		next := make([]*dns.SVCBRecord, 0)
+		for _, record := range records {
+			target, ok := record.TargetName()
+			if !ok {
+				continue
+			}
+			seen, qtypes := endpoints, []dns.QueryType{dns.A, dns.AAAA}
+			if record.IsAlias() {
+				seen, qtypes = aliases, []dns.QueryType{qtype}
+			}
+			if seen[dns.CanonicalName(target)] {
+				continue
+			}
+			seen[dns.CanonicalName(target)] = true
+
+			for _, t := range qtypes {
+				if hasRecords(response.Resources, target, t) {
+					continue
+				}
+				step, err := r.resolve(ctx, b, target, t)
+				if errors.Is(err, ErrBudgetExceeded) || ctx.Err() != nil {
+					fmt.Printf("Stopped adding service targets of %v: %v\n", response.Questions[0].Name, err)
+					return
+				}
+				if err != nil {
+					fmt.Printf("Lookup of service target %v %v failed: %v\n", t, target, err)
+					continue
+				}
+				response.Resources = append(response.Resources, step.Answers...)
+				if record.IsAlias() {
+					next = append(next, serviceBindings(step.Answers, qtype)...)
+				}
+			}
+		}
+		records = next
+	}
+}
+
+// serviceBindings returns the SVCB or HTTPS records of qtype among records.
+func serviceBindings(records []dns.DnsRecord, qtype dns.QueryType) []*dns.SVCBRecord {
+	bindings := make([]*dns.SVCBRecord, 0)
+	for _, record := range records {
+		switch record := record.(type) {
+		case *dns.SVCBRecord:
+			if qtype == dns.SVCB {
+				bindings = append(bindings, record)
+			}
+		case *dns.HTTPSRecord:
+			if qtype == dns.HTTPS {
+				bindings = append(bindings, &record.SVCBRecord)
+			}
+		}
+	}
+	return bindings
+}
+
+// hasRecords reports whether records hold records of qtype for name.
+func hasRecords(records []dns.DnsRecord, name string, qtype dns.QueryType) bool {
+	for _, record := range records {
+		if dns.EqualNames(record.GetDomain(), name) && dns.RecordType(record) == qtype {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,233 @@
+package resolver
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/sadityakumar9211/go-res/internal/dns"
+	buf "github.com/sadityakumar9211/go-res/pkg/bytepacketbuffer"
+)
+
+// exchangeTimeout bounds a single exchange with a name server.
+const exchangeTimeout = 5 * time.Second
+
+// randomPortAttempts is how many random source ports we try to bind before
+// letting the operating system pick one.
+const randomPortAttempts = 10
+
+// isTruncated reports whether the response held in resBuffer has the TC bit
+// set. Only the header is looked at, as the rest of a truncated message may
+// not parse.
+func isTruncated(resBuffer *buf.BytePacketBuffer) bool {
+	header := dns.NewDnsHeader()
+	err := header.Read(resBuffer)
+	resBuffer.Seek(0)
+	return err == nil && header.TruncatedMessage
+}
+
+// exchange sends request, encoded in reqBuffer, to server over network and
+// returns a buffer holding the raw response. Only a response matching the
+// request is returned, see checkResponse.
+func exchange(ctx context.Context, network string, server string, request *dns.DnsPacket, reqBuffer *buf.BytePacketBuffer, exactCase bool) (*buf.BytePacketBuffer, error) {
+	// 5 second deadline for read and write operation to this socket, unless
+	// the query as a whole has to be answered sooner than that.
+	deadline := time.Now().Add(exchangeTimeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+
+	if network == "tcp" {
+		return exchangeTCP(ctx, server, request, reqBuffer, deadline, exactCase)
+	}
+	return exchangeUDP(server, request, reqBuffer, deadline, exactCase)
+}
+
+// exchangeTCP sends the query over a new TCP connection.
+func exchangeTCP(ctx context.Context, server string, request *dns.DnsPacket, reqBuffer *buf.BytePacketBuffer, deadline time.Time, exactCase bool) (*buf.BytePacketBuffer, error) {
+	var dialer net.Dialer
+	socket, err := dialer.DialContext(ctx, "tcp", server)
+	if err != nil {
+		return nil, err
+	}
+	defer socket.Close()
+	socket.SetDeadline(deadline)
+
+	// Over TCP every message is prefixed with its length.
+	resBuffer := buf.NewBytePacketBufferSize(buf.MaxSize)
+	if err := dns.WriteTCPMessage(socket, reqBuffer); err != nil {
+		return nil, err
+	}
+	if err := dns.ReadTCPMessage(socket, &resBuffer); err != nil {
+		return nil, err
+	}
+	if err := checkResponse(request, &resBuffer, exactCase); err != nil {
+		return nil, fmt.Errorf("response from %v: %w", server, err)
+	}
+	return &resBuffer, nil
+}
+
+// exchangeUDP sends the query from a fresh socket bound to a random port.
+// Together with the random query ID, this leaves an off-path attacker 32 bits
+// to guess to get a forged response accepted. Datagrams which don't come from
+// server or don't match the query are discarded, and we keep waiting for the
+// real response until the deadline.
+func exchangeUDP(server string, request *dns.DnsPacket, reqBuffer *buf.BytePacketBuffer, deadline time.Time, exactCase bool) (*buf.BytePacketBuffer, error) {
+	serverAddr, err := net.ResolveUDPAddr("udp", server)
+	if err != nil {
+		return nil, err
+	}
+
+	socket, err := listenRandomPort()
+	if err != nil {
+		return nil, err
+	}
+	defer socket.Close()
+	socket.SetDeadline(deadline)
+
+	_, err = socket.WriteToUDP(reqBuffer.Buf[:reqBuffer.GetPos()], serverAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		data := make([]byte, ednsBufferSize)
+		n, src, err := socket.ReadFromUDP(data)
+		if err != nil {
+			return nil, err
+		}
+
+		if !src.IP.Equal(serverAddr.IP) || src.Port != serverAddr.Port {
+			fmt.Printf("Discarding response from %v while waiting for %v\n", src, serverAddr)
+			continue
+		}
+
+		resBuffer := buf.FromBytes(data[:n])
+		if err := checkResponse(request, &resBuffer, exactCase); err != nil {
+			fmt.Printf("Discarding response from %v: %v\n", src, err)
+			continue
+		}
+		return &resBuffer, nil
+	}
+}
+
+// listenRandomPort binds a UDP socket to a random source port.
+func listenRandomPort() (*net.UDPConn, error) {
+	for i := 0; i < randomPortAttempts; i++ {
+		port, err := randomUint16()
+		if err != nil {
+			return nil, err
+		}
+		// Stay clear of the well known ports.
+		if port < 1024 {
+			continue
+		}
+
+		socket, err := net.ListenUDP("udp", &net.UDPAddr{Port: int(port)})
+		if err == nil {
+			return socket, nil
+		}
+	}
+
+	// Every port we tried was taken, the ephemeral port picked by the
+	// operating system will have to do.
+	return net.ListenUDP("udp", &net.UDPAddr{})
+}
+
+// checkResponse verifies that the message in resBuffer is a response to
+// request: it must carry the same ID and echo the question that was asked.
+// With exactCase set, the name in the question must be echoed with the same
+// case, see randomiseCase.
+func checkResponse(request *dns.DnsPacket, resBuffer *buf.BytePacketBuffer, exactCase bool) error {
+	defer resBuffer.Seek(0)
+
+	header := dns.NewDnsHeader()
+	if err := header.Read(resBuffer); err != nil {
+		return err
+	}
+	if !header.Response {
+		return errors.New("message is not a response")
+	}
+	if header.ID != request.Header.ID {
+		return fmt.Errorf("ID %d does not match query ID %d", header.ID, request.Header.ID)
+	}
+
+	// Servers which can't make sense of a query may answer without repeating
+	// the question. Such responses carry no data we could be fooled by.
+	if header.Questions == 0 && (header.ResultCode == dns.FORMERR || header.ResultCode == dns.NOTIMP) {
+		return nil
+	}
+	if header.Questions != 1 {
+		return fmt.Errorf("response has %d questions", header.Questions)
+	}
+
+	question := dns.DnsQuestion{}
+	if err := question.Read(resBuffer); err != nil {
+		return err
+	}
+	asked := request.Questions[0]
+	if !dns.EqualNames(question.Name, asked.Name) || question.QType != asked.QType || question.Class() != asked.Class() {
+		return fmt.Errorf("question %q does not match query for %q", question.Name, asked.Name)
+	}
+	if exactCase && question.Name != asked.Name {
+		return fmt.Errorf("question %q does not echo the case of %q", question.Name, asked.Name)
+	}
+
+	return nil
+}
+
+// randomiseCase returns name with the case of each of its letters chosen at
+// random, as proposed in draft-vixie-dnsext-dns0x20. Name servers copy the
+// question into the response as is, so that a response not echoing the same
+// case can be told apart as forged.
+func randomiseCase(name string) (string, error) {
+	bits := make([]byte, (len(name)+7)/8)
+	if _, err := rand.Read(bits); err != nil {
+		return "", err
+	}
+
+	randomised := []byte(name)
+	for i, ch := range randomised {
+		lower := ch | 0x20
+		if lower < 'a' || lower > 'z' {
+			continue
+		}
+		if bits[i/8]&(1<<(i%8)) != 0 {
+			randomised[i] = lower &^ 0x20
+		} else {
+			randomised[i] = lower
+		}
+	}
+	return string(randomised), nil
+}
+
+// restoreCase undoes randomiseCase once the response has been checked: the
+// question and the owner names equal to it get back the case of qname, so
+// that the random case is neither cached nor passed on to clients.
+func restoreCase(response *dns.DnsPacket, qname string) {
+	for _, question := range response.Questions {
+		if dns.EqualNames(question.Name, qname) {
+			question.Name = qname
+		}
+	}
+	for _, section := range [][]dns.DnsRecord{response.Answers, response.Authorities, response.Resources} {
+		for i, record := range section {
+			if record.GetDomain() != qname && dns.EqualNames(record.GetDomain(), qname) {
+				section[i] = record.WithDomain(qname)
+			}
+		}
+	}
+}
+
+// randomUint16 returns a cryptographically random 16 bit number.
+func randomUint16() (uint16, error) {
+	var b [2]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint16(b[:]), nil
+}
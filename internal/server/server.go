@@ -0,0 +1,309 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/sadityakumar9211/go-res/internal/dns"
+	"github.com/sadityakumar9211/go-res/pkg/bytepacketbuffer"
+)
+
+// Handler answers a single parsed DNS request. The context is cancelled once
+// the per-query timeout expires, telling the handler to stop working on the
+// request; whatever packet it then returns is still sent to the client.
+type Handler func(ctx context.Context, request *dns.DnsPacket) *dns.DnsPacket
+
+// Config holds the tunables of the DNS server.
+type Config struct {
+	Addr         string        // address to listen on, e.g. "0.0.0.0:2053"
+	Workers      int           // number of queries resolved concurrently
+	QueueSize    int           // number of queries waiting for a free worker
+	QueryTimeout time.Duration // upper bound on resolving a single query
+	DropWhenBusy bool          // drop queries instead of answering REFUSED when saturated
+	IdleTimeout  time.Duration // how long an idle TCP connection is kept open
+	MaxUDPSize   int           // largest UDP response we send to EDNS clients
+
+	DisableCompression bool // write every name in full, for debugging
+}
+
+// DefaultConfig returns the configuration used when nothing else is specified.
+func DefaultConfig() Config {
+	return Config{
+		Addr:         "0.0.0.0:2053",
+		Workers:      64,
+		QueueSize:    256,
+		QueryTimeout: 10 * time.Second,
+		IdleTimeout:  10 * time.Second,
+		MaxUDPSize:   1232,
+	}
+}
+
+// query is a raw query received from a client waiting for a worker. The
+// reply function sends the response to request back over the transport it
+// came from, and done, when set, is called once the query has been dealt with.
+type query struct {
+	buffer bytepacketbuffer.BytePacketBuffer
+	src    net.Addr
+	reply  func(request, response *dns.DnsPacket) error
+	done   func()
+}
+
+// finish marks the query as dealt with.
+func (q *query) finish() {
+	if q.done != nil {
+		q.done()
+	}
+}
+
+// Server reads DNS queries continuously and resolves them on a bounded pool
+// of workers so that a single slow lookup does not stall other clients.
+type Server struct {
+	config  Config
+	handler Handler
+	queue   chan *query
+}
+
+// New creates a new Server which answers queries using handler.
+func New(config Config, handler Handler) *Server {
+	defaults := DefaultConfig()
+	if config.Addr == "" {
+		config.Addr = defaults.Addr
+	}
+	if config.Workers <= 0 {
+		config.Workers = defaults.Workers
+	}
+	if config.QueueSize < 0 {
+		config.QueueSize = 0
+	}
+	if config.QueryTimeout <= 0 {
+		config.QueryTimeout = defaults.QueryTimeout
+	}
+	if config.IdleTimeout <= 0 {
+		config.IdleTimeout = defaults.IdleTimeout
+	}
+	if config.MaxUDPSize <= 0 {
+		config.MaxUDPSize = defaults.MaxUDPSize
+	}
+	if config.MaxUDPSize < bytepacketbuffer.DefaultSize {
+		config.MaxUDPSize = bytepacketbuffer.DefaultSize
+	}
+	if config.MaxUDPSize > bytepacketbuffer.MaxSize {
+		config.MaxUDPSize = bytepacketbuffer.MaxSize
+	}
+
+	s := &Server{
+		config:  config,
+		handler: handler,
+		queue:   make(chan *query, config.QueueSize),
+	}
+	for i := 0; i < config.Workers; i++ {
+		go s.worker()
+	}
+	return s
+}
+
+// ListenAndServe binds a UDP socket and a TCP listener on the configured
+// address and serves queries on both until one of them fails.
+func (s *Server) ListenAndServe() error {
+	udpAddr, err := net.ResolveUDPAddr("udp", s.config.Addr)
+	if err != nil {
+		return fmt.Errorf("resolving UDP address: %w", err)
+	}
+
+	socket, err := net.ListenUDP("udp", udpAddr)
+	if err != nil {
+		return fmt.Errorf("binding UDP socket: %w", err)
+	}
+	defer socket.Close()
+
+	listener, err := net.Listen("tcp", s.config.Addr)
+	if err != nil {
+		return fmt.Errorf("binding TCP listener: %w", err)
+	}
+	defer listener.Close()
+
+	errs := make(chan error, 2)
+	go func() { errs <- s.ServeUDP(socket) }()
+	go func() { errs <- s.ServeTCP(listener) }()
+
+	return <-errs
+}
+
+// ServeUDP serves queries arriving on an already bound socket.
+func (s *Server) ServeUDP(socket *net.UDPConn) error {
+	for {
+		// The `ReadFromUDP` function will write the data into the provided buffer,
+		// and return the length of the data read as well as the source address.
+		// We need to keep track of the source in order to send our reply later on.
+		data := make([]byte, s.config.MaxUDPSize)
+		n, src, err := socket.ReadFromUDP(data)
+		if err != nil {
+			return err
+		}
+
+		query := &query{buffer: bytepacketbuffer.FromBytes(data[:n]), src: src}
+		query.reply = func(request, response *dns.DnsPacket) error {
+			return s.writeResponse(socket, response, src, s.udpSize(request))
+		}
+
+		s.dispatch(query)
+	}
+}
+
+// dispatch hands the query over to a worker without blocking the caller. When
+// every worker is busy and the queue is full we apply back-pressure.
+func (s *Server) dispatch(query *query) {
+	select {
+	case s.queue <- query:
+	default:
+		s.refuse(query)
+	}
+}
+
+// worker resolves queued queries one at a time.
+func (s *Server) worker() {
+	for query := range s.queue {
+		if err := s.handleQuery(query); err != nil {
+			fmt.Println("Error handling DNS query:", err)
+		}
+		query.finish()
+	}
+}
+
+// handleQuery parses a queued query, resolves it and sends the response.
+func (s *Server) handleQuery(query *query) error {
+	request, err := dns.FromBuffer(&query.buffer)
+	if err != nil {
+		return err
+	}
+
+	// We only implement version 0 of EDNS, anything newer is answered with
+	// BADVERS without looking at the question.
+	if opt := request.GetOPT(); opt != nil && opt.Version > 0 {
+		response := NewResponse(request)
+		s.applyEDNS(request, response)
+		response.GetOPT().ExtendedRCode = dns.ExtendedRCodeBADVERS
+		return query.reply(request, response)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.config.QueryTimeout)
+	defer cancel()
+
+	response := s.handler(ctx, request)
+	if response == nil {
+		return nil
+	}
+
+	s.applyEDNS(request, response)
+	return query.reply(request, response)
+}
+
+// applyEDNS replaces whatever OPT record the handler left in the response
+// with our own, if and only if the client sent one in its request.
+func (s *Server) applyEDNS(request, response *dns.DnsPacket) {
+	response.RemoveOPT()
+
+	reqOpt := request.GetOPT()
+	if reqOpt == nil {
+		return
+	}
+
+	opt := dns.NewOPTRecord(uint16(s.config.MaxUDPSize))
+	opt.DNSSECOK = reqOpt.DNSSECOK
+	response.Resources = append(response.Resources, opt)
+}
+
+// udpSize returns the largest UDP response the client of request can receive.
+// Without EDNS that's the classic 512 bytes, otherwise it's whatever the
+// client advertised, capped by our own limit.
+func (s *Server) udpSize(request *dns.DnsPacket) int {
+	opt := request.GetOPT()
+	if opt == nil || int(opt.UDPPayloadSize) < bytepacketbuffer.DefaultSize {
+		return bytepacketbuffer.DefaultSize
+	}
+	if int(opt.UDPPayloadSize) > s.config.MaxUDPSize {
+		return s.config.MaxUDPSize
+	}
+	return int(opt.UDPPayloadSize)
+}
+
+// refuse is called when the server is saturated. Depending on the
+// configuration, the query is either dropped silently or answered with
+// `REFUSED` so the client can move on to another resolver right away.
+func (s *Server) refuse(query *query) {
+	defer query.finish()
+
+	if s.config.DropWhenBusy {
+		fmt.Println("Server busy, dropping query from", query.src)
+		return
+	}
+
+	request, err := dns.FromBuffer(&query.buffer)
+	if err != nil {
+		return
+	}
+
+	fmt.Println("Server busy, refusing query from", query.src)
+	response := NewResponse(request)
+	response.Header.ResultCode = dns.REFUSED
+	s.applyEDNS(request, response)
+	if err := query.reply(request, response); err != nil {
+		fmt.Println("Error refusing DNS query:", err)
+	}
+}
+
+// NewResponse creates a response packet for request, echoing its ID and questions.
+func NewResponse(request *dns.DnsPacket) *dns.DnsPacket {
+	response := dns.NewDnsPacket()
+	response.Header.ID = request.Header.ID
+	response.Header.RecursionDesired = request.Header.RecursionDesired
+	response.Header.RecursionAvailable = true
+	response.Header.Response = true
+	response.Questions = append(response.Questions, request.Questions...)
+	return response
+}
+
+// newBuffer creates a buffer for encoding a response of at most size bytes.
+func (s *Server) newBuffer(size int) bytepacketbuffer.BytePacketBuffer {
+	buffer := bytepacketbuffer.NewBytePacketBufferSize(size)
+	buffer.DisableCompression = s.config.DisableCompression
+	return buffer
+}
+
+// writeResponse encodes response and sends it off to dst, truncating it if it
+// is larger than size.
+func (s *Server) writeResponse(socket *net.UDPConn, response *dns.DnsPacket, dst *net.UDPAddr, size int) error {
+	resBuffer := s.newBuffer(size)
+	if err := response.Write(&resBuffer); errors.Is(err, bytepacketbuffer.ErrWouldTruncate) {
+		// The response does not fit into what the client can receive over UDP.
+		// Send back only the header and question with TC set, so the client
+		// knows to retry over TCP.
+		resBuffer = s.newBuffer(size)
+		if err := truncate(response).Write(&resBuffer); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+
+	_, err := socket.WriteToUDP(resBuffer.Buf[:resBuffer.GetPos()], dst)
+	return err
+}
+
+// truncate returns a copy of response without any records but the OPT record
+// and with the TC bit set.
+func truncate(response *dns.DnsPacket) *dns.DnsPacket {
+	header := *response.Header
+	header.TruncatedMessage = true
+
+	truncated := dns.NewDnsPacket()
+	truncated.Header = &header
+	truncated.Questions = append(truncated.Questions, response.Questions...)
+	if opt := response.GetOPT(); opt != nil {
+		truncated.Resources = append(truncated.Resources, opt)
+	}
+	return truncated
+}
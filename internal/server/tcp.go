@@ -0,0 +1,91 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/sadityakumar9211/go-res/internal/dns"
+	"github.com/sadityakumar9211/go-res/pkg/bytepacketbuffer"
+)
+
+// ServeTCP accepts connections on an already bound listener and serves the
+// queries arriving on each of them.
+func (s *Server) ServeTCP(listener net.Listener) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				time.Sleep(10 * time.Millisecond)
+				continue
+			}
+			return err
+		}
+		go s.serveConn(conn)
+	}
+}
+
+// serveConn reads length prefixed queries from a single TCP connection. Queries
+// are pipelined: each one is handed to the worker pool as soon as it has been
+// read, and responses are written back in whatever order they complete.
+func (s *Server) serveConn(conn net.Conn) {
+	var (
+		inflight sync.WaitGroup
+		writeMu  sync.Mutex
+	)
+	defer func() {
+		// Let outstanding queries finish writing before closing the connection.
+		inflight.Wait()
+		conn.Close()
+	}()
+
+	reply := func(request, response *dns.DnsPacket) error {
+		resBuffer := s.newBuffer(bytepacketbuffer.MaxSize)
+		if err := response.Write(&resBuffer); errors.Is(err, bytepacketbuffer.ErrWouldTruncate) {
+			// The response does not fit into a DNS message at all. Unlike
+			// over UDP there is no other transport to retry on, so send back
+			// only the header and question with SERVFAIL.
+			failed := truncate(response)
+			failed.Header.TruncatedMessage = false
+			failed.Header.ResultCode = dns.SERVFAIL
+			resBuffer = s.newBuffer(bytepacketbuffer.MaxSize)
+			if err := failed.Write(&resBuffer); err != nil {
+				return err
+			}
+		} else if err != nil {
+			return err
+		}
+
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		conn.SetWriteDeadline(time.Now().Add(s.config.IdleTimeout))
+		return dns.WriteTCPMessage(conn, &resBuffer)
+	}
+
+	for {
+		// The connection is closed once the client has been idle for too long.
+		conn.SetReadDeadline(time.Now().Add(s.config.IdleTimeout))
+
+		buffer := bytepacketbuffer.NewBytePacketBufferSize(bytepacketbuffer.MaxSize)
+		if err := dns.ReadTCPMessage(conn, &buffer); err != nil {
+			// The client closed the connection, went idle or the stream broke.
+			var netErr net.Error
+			if !errors.Is(err, io.EOF) && !(errors.As(err, &netErr) && netErr.Timeout()) {
+				fmt.Println("Error reading DNS query over TCP:", err)
+			}
+			return
+		}
+
+		inflight.Add(1)
+		s.dispatch(&query{
+			buffer: buffer,
+			src:    conn.RemoteAddr(),
+			reply:  reply,
+			done:   inflight.Done,
+		})
+	}
+}
@@ -2,17 +2,67 @@ package bytepacketbuffer
 
 import (
 	"errors"
+	"strings"
 )
 
-// BytePacketBuffer is a buffer for working with binary data.
+const (
+	// DefaultSize is the classic size limit of a DNS message sent over UDP.
+	DefaultSize = 512
+	// MaxSize is the largest DNS message there can be, as bounded by the two
+	// byte length prefix used over TCP.
+	MaxSize = 65535
+)
+
+var (
+	// ErrEndOfBuffer is returned when reading past the end of the data held by the buffer.
+	ErrEndOfBuffer = errors.New("end of buffer")
+	// ErrWouldTruncate is returned when a write would grow the message beyond
+	// the maximum size negotiated for it.
+	ErrWouldTruncate = errors.New("write would truncate message")
+)
+
+// BytePacketBuffer is a buffer for working with binary data. The buffer grows
+// as it is written to, up to Size bytes.
 type BytePacketBuffer struct {
-	Buf [512]byte
-	Pos int // buffer pointer to track current position.
+	Buf  []byte
+	Pos  int // buffer pointer to track current position.
+	Size int // maximum size of the message, DefaultSize when zero.
+
+	// DisableCompression makes WriteQName write every name in full, which
+	// makes messages easier to follow in a packet dump.
+	DisableCompression bool
+
+	names map[string]int // offsets of the names written so far, for compression.
 }
 
 // NewBytePacketBuffer creates and returns a new BytePacketBuffer with default values.
 func NewBytePacketBuffer() BytePacketBuffer {
-	return BytePacketBuffer{}
+	return BytePacketBuffer{Buf: make([]byte, DefaultSize), Size: DefaultSize}
+}
+
+// NewBytePacketBufferSize creates and returns an empty BytePacketBuffer which
+// can be written to until it holds size bytes.
+func NewBytePacketBufferSize(size int) BytePacketBuffer {
+	if size <= 0 {
+		size = DefaultSize
+	}
+	if size > MaxSize {
+		size = MaxSize
+	}
+	return BytePacketBuffer{Size: size}
+}
+
+// FromBytes creates a BytePacketBuffer for reading the message held in data.
+func FromBytes(data []byte) BytePacketBuffer {
+	return BytePacketBuffer{Buf: data, Size: len(data)}
+}
+
+// MaxSize returns the maximum size the message in the buffer can grow to.
+func (b *BytePacketBuffer) MaxSize() int {
+	if b.Size <= 0 {
+		return DefaultSize
+	}
+	return b.Size
 }
 
 // GetPos returns the current buffer pointer.
@@ -34,8 +84,8 @@ func (b *BytePacketBuffer) Seek(pos int) error {
 
 // Read reads a single byte from buffer and moves buffer pointer by same amount.
 func (b *BytePacketBuffer) Read() (byte, error) {
-	if b.Pos >= 512 {
-		return 0, errors.New("end of buffer")
+	if b.Pos < 0 || b.Pos >= len(b.Buf) {
+		return 0, ErrEndOfBuffer
 	}
 	res := b.Buf[b.Pos]
 	b.Pos++
@@ -44,16 +94,16 @@ func (b *BytePacketBuffer) Read() (byte, error) {
 
 // Get returns a buffer byte at pos without changing buffer pointer.
 func (b *BytePacketBuffer) Get(pos int) (byte, error) {
-	if pos >= 512 {
-		return 0, errors.New("end of buffer")
+	if pos < 0 || pos >= len(b.Buf) {
+		return 0, ErrEndOfBuffer
 	}
 	return b.Buf[pos], nil
 }
 
-// GetRange returns buffer bits from start with specified length without moving buffer pointer. 
+// GetRange returns buffer bits from start with specified length without moving buffer pointer.
 func (b *BytePacketBuffer) GetRange(start int, length int) ([]byte, error) {
-	if start+length >= 512 {
-		return nil, errors.New("end of buffer")
+	if start < 0 || length < 0 || start+length > len(b.Buf) {
+		return nil, ErrEndOfBuffer
 	}
 	return b.Buf[start : start+length], nil
 }
@@ -149,8 +199,11 @@ func (b *BytePacketBuffer) ReadQName(outstr *string) error {
 
 // Write writes a byte to buffer and moves buffer pointer.
 func (b *BytePacketBuffer) Write(val byte) error {
-	if b.Pos >= 512 {
-		return errors.New("end of buffer")
+	if b.Pos >= b.MaxSize() {
+		return ErrWouldTruncate
+	}
+	if b.Pos >= len(b.Buf) {
+		b.grow(b.Pos + 1)
 	}
 	b.Buf[b.Pos] = val
 	b.Pos++
@@ -164,47 +217,109 @@ func (b *BytePacketBuffer) WriteU8(val byte) error {
 
 // WriteU16 writes 2 bytes to buffer and moves buffer pointer.
 func (b *BytePacketBuffer) WriteU16(val uint16) error {
-	b.Write(byte(val >> 8))
-	b.Write(byte(val & 0xFF))
-	return nil
+	if err := b.Write(byte(val >> 8)); err != nil {
+		return err
+	}
+	return b.Write(byte(val & 0xFF))
 }
 
 // WriteU32 writes 4 bytes to buffer and moves buffer pointer.
 func (b *BytePacketBuffer) WriteU32(val uint32) error {
-	b.Write(byte((val >> 24) & 0xFF))
-	b.Write(byte((val >> 16) & 0xFF))
-	b.Write(byte((val >> 8) & 0xFF))
-	b.Write(byte(val & 0xFF))
-	return nil
+	if err := b.WriteU16(uint16(val >> 16)); err != nil {
+		return err
+	}
+	return b.WriteU16(uint16(val & 0xFFFF))
 }
 
 // WriteQName writes Question name to the buffer and moves buffer pointer.
+// Unless compression is disabled, the longest suffix of the name that was
+// already written to the message is replaced by a pointer to it (RFC 1035
+// section 4.1.4).
 func (b *BytePacketBuffer) WriteQName(qname string) error {
-	for _, label := range SplitDNSName(qname) {
-		lenVal := byte(len(label))
-		if lenVal > 0x34 {
+	return b.writeQName(qname, !b.DisableCompression)
+}
+
+// WriteQNameUncompressed writes a name without compression pointers, for the
+// record types whose data must not be compressed (RFC 3597 section 4).
+func (b *BytePacketBuffer) WriteQNameUncompressed(qname string) error {
+	return b.writeQName(qname, false)
+}
+
+func (b *BytePacketBuffer) writeQName(qname string, compress bool) error {
+	// The root is written as a single empty label.
+	if qname == "" {
+		return b.WriteU8(0)
+	}
+
+	labels := SplitDNSName(qname)
+	for i, label := range labels {
+		suffix := strings.Join(labels[i:], ".")
+		if compress {
+			if offset, ok := b.names[suffix]; ok {
+				return b.WriteU16(0xC000 | uint16(offset))
+			}
+		}
+
+		// Pointers only have 14 bits for the offset, names further into the
+		// message can't be pointed at.
+		if b.Pos < 0x4000 {
+			if b.names == nil {
+				b.names = make(map[string]int)
+			}
+			if _, ok := b.names[suffix]; !ok {
+				b.names[suffix] = b.Pos
+			}
+		}
+
+		if len(label) > 0x3F {
 			return errors.New("single label exceeds 63 characters of length")
 		}
-		b.WriteU8(lenVal)
-		for _, ch := range label {
-			b.Write(byte(ch))
+		if err := b.WriteU8(byte(len(label))); err != nil {
+			return err
+		}
+		for i := 0; i < len(label); i++ {
+			if err := b.Write(label[i]); err != nil {
+				return err
+			}
 		}
 	}
-	b.WriteU8(0)
-	return nil
+	return b.WriteU8(0)
 }
 
 // Set overwrite a byte from the given position.
 func (b *BytePacketBuffer) Set(pos int, val byte) error {
+	if pos < 0 || pos >= len(b.Buf) {
+		return ErrEndOfBuffer
+	}
 	b.Buf[pos] = val
 	return nil
 }
 
 // SetU16 overwrites 2 bytes from the given position.
 func (b *BytePacketBuffer) SetU16(pos int, val uint16) error {
-	b.Set(pos, byte(val>>8))
-	b.Set(pos+1, byte(val&0xFF))
-	return nil
+	if err := b.Set(pos, byte(val>>8)); err != nil {
+		return err
+	}
+	return b.Set(pos+1, byte(val&0xFF))
+}
+
+// grow extends the buffer so that it holds at least length bytes. The
+// capacity is doubled each time to keep the number of copies low.
+func (b *BytePacketBuffer) grow(length int) {
+	capacity := 2 * len(b.Buf)
+	if capacity < DefaultSize {
+		capacity = DefaultSize
+	}
+	if capacity < length {
+		capacity = length
+	}
+	if capacity > b.MaxSize() {
+		capacity = b.MaxSize()
+	}
+
+	buf := make([]byte, capacity)
+	copy(buf, b.Buf)
+	b.Buf = buf
 }
 
 // SplitDNSName splits question name string to multiple labels and returns an slice of labels.